@@ -0,0 +1,60 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/thejokersthief/go-enc/enc"
+)
+
+func canaryCommand(config *enc.Config, command string) {
+	var working_enc *enc.ENC
+	if working_enc, commandErr = config.GetENC(*enc_name); commandErr != nil {
+		return
+	}
+
+	var canary *enc.Canary
+	switch command {
+	case canaryCreate.FullCommand():
+		parameters := make(map[string]interface{}, len(*canaryCreateParams))
+		for _, param := range *canaryCreateParams {
+			split := strings.SplitN(param, "=", 2)
+			if len(split) != 2 {
+				handleErr(fmt.Errorf("Parameter should be path=value: %s", param))
+			}
+
+			value, err := enc.ParseValue(split[1], *canaryCreateType)
+			handleErr(err)
+			parameters[split[0]] = value
+		}
+
+		canary, commandErr = working_enc.CreateCanary(*canaryCreateNodegroup, *canaryCreateName, *canaryCreateCount, *canaryCreatePercent, parameters, *canaryCreateEnvironment)
+	case canaryPromote.FullCommand():
+		canary, commandErr = working_enc.PromoteCanary(*canaryPromoteName)
+	case canaryAbort.FullCommand():
+		canary, commandErr = working_enc.AbortCanary(*canaryAbortName)
+	}
+	if commandErr != nil {
+		return
+	}
+
+	if commandErr = printChanges(*canaryOutput, canary.Changes, canary); commandErr != nil {
+		return
+	}
+
+	message := fmt.Sprintf("Moved %s into %s", strings.Join(canary.Nodes, ", "), canary.Nodegroup)
+	switch command {
+	case canaryPromote.FullCommand():
+		message = fmt.Sprintf("Promoted %s into %s and moved back %s", canary.Nodegroup, canary.Parent, strings.Join(canary.Nodes, ", "))
+	case canaryAbort.FullCommand():
+		message = fmt.Sprintf("Removed %s and moved back %s to %s", canary.Nodegroup, strings.Join(canary.Nodes, ", "), canary.Parent)
+	}
+	if *canaryDryRun {
+		fmt.Fprintf(os.Stderr, "Dry run: %s\n", message)
+		return
+	}
+
+	config.WriteOutENC()
+	fmt.Fprintln(os.Stderr, message)
+}
@@ -0,0 +1,46 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/thejokersthief/go-enc/enc"
+)
+
+// classification is the document Puppet expects back from an External Node Classifier
+type classification struct {
+	Classes     map[string]interface{} `json:"classes" yaml:"classes"`
+	Parameters  map[string]interface{} `json:"parameters,omitempty" yaml:"parameters,omitempty"`
+	Environment string                 `json:"environment,omitempty" yaml:"environment,omitempty"`
+}
+
+func classifyCommand(config *enc.Config) {
+	var lookup *enc.NodeLookup
+	if lookup, commandErr = lookupNode(config, *classifyNode); commandErr != nil {
+		return
+	}
+
+	classes := lookup.Nodegroup.Classes
+	if classes == nil {
+		classes = map[string]interface{}{}
+	}
+
+	// Hiera's groups level maps over the nodegroups variable, unless the ENC sets its own
+	parameters := make(map[string]interface{}, len(lookup.Nodegroup.Parameters)+1)
+	for key, value := range lookup.Nodegroup.Parameters {
+		parameters[key] = value
+	}
+	if _, ok := parameters["nodegroups"]; !ok {
+		parameters["nodegroups"] = config.HieraNodegroups(lookup.Node, lookup.ENCs)
+	}
+
+	// Puppet reads the classification from stdout, so say which ENC answered on stderr
+	fmt.Fprintf(os.Stderr, "Classified %s from: %s\n", lookup.Node, strings.Join(lookup.ENCs, ", "))
+
+	commandErr = printStructured(*classifyOutput, classification{
+		Classes:     classes,
+		Parameters:  parameters,
+		Environment: lookup.Nodegroup.Environment,
+	})
+}
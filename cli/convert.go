@@ -0,0 +1,50 @@
+package cli
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/thejokersthief/go-enc/enc"
+)
+
+// convertCommand writes the ENC to a new file in another format, leaving the original in place
+func convertCommand(config *enc.Config) {
+	var working_enc *enc.ENC
+	if working_enc, commandErr = config.GetENC(*enc_name); commandErr != nil {
+		return
+	}
+
+	fileName := *convertFile
+	if fileName == "" {
+		fileName = strings.TrimSuffix(working_enc.FileName, filepath.Ext(working_enc.FileName)) + "." + *convertFormat
+	}
+
+	format, err := enc.FormatForFile(fileName)
+	if err != nil {
+		commandErr = err
+		return
+	}
+	if format != *convertFormat {
+		commandErr = fmt.Errorf("File extension doesn't match the format %s: %s", *convertFormat, fileName)
+		return
+	}
+
+	if _, err := os.Stat(fileName); err == nil && !*convertOverwrite {
+		commandErr = fmt.Errorf("File already exists, use --overwrite to replace it: %s", fileName)
+		return
+	}
+
+	var contents []byte
+	if contents, commandErr = working_enc.Encode(format); commandErr != nil {
+		return
+	}
+
+	if commandErr = ioutil.WriteFile(fileName, contents, 0644); commandErr != nil {
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "Converted %s to %s\n", working_enc.FileName, fileName)
+}
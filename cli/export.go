@@ -0,0 +1,59 @@
+package cli
+
+import (
+	"strings"
+
+	"github.com/thejokersthief/go-enc/enc"
+)
+
+func exportAnsibleCommand(config *enc.Config) {
+	if *exportAnsibleHost != "" {
+		var hostVars map[string]interface{}
+		if hostVars, commandErr = config.AnsibleHostVars(*exportAnsibleHost, *node_conflict); commandErr != nil {
+			return
+		}
+
+		commandErr = printStructured("json", hostVars)
+		return
+	}
+
+	var inventory *enc.AnsibleInventory
+	if inventory, commandErr = config.AnsibleInventory(*node_conflict); commandErr != nil {
+		return
+	}
+
+	if *exportAnsibleList {
+		commandErr = printStructured("json", inventory)
+	} else {
+		commandErr = printStructured("yaml", inventory)
+	}
+}
+
+func exportHieraCommand(config *enc.Config) {
+	var data *enc.HieraData
+	if data, commandErr = config.HieraData(*node_conflict, *exportHieraGroups); commandErr != nil {
+		return
+	}
+
+	commandErr = data.WriteOut(*exportHieraDir)
+}
+
+func exportSaltCommand(config *enc.Config) {
+	commandErr = config.SaltExport().WriteOut(*exportSaltDir)
+}
+
+func exportPrometheusSDCommand(config *enc.Config) {
+	params := []string{}
+	for _, param := range *exportPrometheusSDParam {
+		params = append(params, strings.Split(param, ",")...)
+	}
+
+	var groups []enc.PrometheusTargetGroup
+	groups, commandErr = config.PrometheusTargets(params, *exportPrometheusSDPort)
+	printWarnings(config)
+	if commandErr != nil {
+		return
+	}
+
+	commandErr = printStructured("json", groups)
+}
@@ -1,9 +1,31 @@
 package cli
 
-import ()
+import (
+	"fmt"
+	"os"
+
+	"github.com/thejokersthief/go-enc/enc"
+)
 
 func handleErr(err error) {
 	if err != nil {
 		panic(err)
 	}
 }
+
+// printWarnings prints the problems a command found that didn't stop it, on stderr
+func printWarnings(config *enc.Config) {
+	for _, warning := range config.Warnings {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+	}
+}
+
+func containsString(list []string, val string) bool {
+	for _, item := range list {
+		if item == val {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,25 @@
+package cli
+
+import (
+	"io/ioutil"
+
+	"github.com/thejokersthief/go-enc/enc"
+)
+
+func importForemanCommand(working_enc *enc.ENC) {
+	hostgroupData, err := ioutil.ReadFile(*importForemanHostgroups)
+	handleErr(err)
+
+	hostData := []byte{}
+	if *importForemanHosts != "" {
+		hostData, err = ioutil.ReadFile(*importForemanHosts)
+		handleErr(err)
+	}
+
+	var report *enc.ForemanImport
+	if report, commandErr = working_enc.ImportForeman(hostgroupData, hostData); commandErr != nil {
+		return
+	}
+
+	commandErr = printStructured(*importForemanOutput, report)
+}
@@ -0,0 +1,281 @@
+package cli
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/thejokersthief/go-enc/enc"
+)
+
+type encListing struct {
+	Name       string `json:"name" yaml:"name"`
+	Type       string `json:"type" yaml:"type"`
+	File       string `json:"file" yaml:"file"`
+	Nodegroups int    `json:"nodegroups" yaml:"nodegroups"`
+	Nodes      int    `json:"nodes" yaml:"nodes"`
+}
+
+type nodegroupListing struct {
+	ENC         string   `json:"enc" yaml:"enc"`
+	Name        string   `json:"name" yaml:"name"`
+	Parent      string   `json:"parent,omitempty" yaml:"parent,omitempty"`
+	Environment string   `json:"environment,omitempty" yaml:"environment,omitempty"`
+	Nodes       []string `json:"nodes" yaml:"nodes"`
+	Classes     []string `json:"classes" yaml:"classes"`
+}
+
+type nodeListing struct {
+	ENC         string   `json:"enc" yaml:"enc"`
+	Name        string   `json:"name" yaml:"name"`
+	Environment string   `json:"environment,omitempty" yaml:"environment,omitempty"`
+	Nodegroups  []string `json:"nodegroups" yaml:"nodegroups"`
+}
+
+type classListing struct {
+	ENC        string   `json:"enc" yaml:"enc"`
+	Name       string   `json:"name" yaml:"name"`
+	Nodegroups []string `json:"nodegroups" yaml:"nodegroups"`
+}
+
+// listing pairs a table row with the value printed for structured output
+type listing struct {
+	row   []string
+	value interface{}
+}
+
+func listCommand(config *enc.Config) {
+	var (
+		headers  []string
+		listings []listing
+	)
+
+	switch *listKind {
+	case "encs":
+		headers, listings = listENCs(config)
+	case "nodegroups":
+		headers, listings = listNodegroups(config)
+	case "nodes":
+		headers, listings, commandErr = listNodes(config)
+	case "classes":
+		headers, listings = listClasses(config)
+	default:
+		handleErr(fmt.Errorf("Invalid kind for command: [command: %s ; kind: %s]", list.FullCommand(), *listKind))
+	}
+
+	if commandErr != nil {
+		return
+	}
+
+	if *listSort != "" || *listReverse {
+		if commandErr = sortListings(headers, listings, *listSort, *listReverse); commandErr != nil {
+			return
+		}
+	}
+
+	rows := make([][]string, 0, len(listings))
+	values := make([]interface{}, 0, len(listings))
+	for _, entry := range listings {
+		rows = append(rows, entry.row)
+		values = append(values, entry.value)
+	}
+
+	commandErr = printOutput(*listOutput, headers, rows, values)
+}
+
+// listedENCs returns the ENCs selected by --enc, or all of them
+func listedENCs(config *enc.Config) []*enc.ENC {
+	encs := make([]*enc.ENC, 0)
+	for _, name := range config.ENCNames() {
+		if *listENC == "" || *listENC == name {
+			encs = append(encs, config.ENCs[name])
+		}
+	}
+
+	return encs
+}
+
+func listENCs(config *enc.Config) ([]string, []listing) {
+	listings := make([]listing, 0)
+	for _, currentEnc := range listedENCs(config) {
+		entry := encListing{
+			Name:       currentEnc.Name,
+			Type:       currentEnc.ConfigType,
+			File:       currentEnc.FileName,
+			Nodegroups: len(currentEnc.Nodegroups),
+			Nodes:      len(currentEnc.NodeNames()),
+		}
+
+		listings = append(listings, listing{
+			row:   []string{entry.Name, entry.Type, entry.File, strconv.Itoa(entry.Nodegroups), strconv.Itoa(entry.Nodes)},
+			value: entry,
+		})
+	}
+
+	return []string{"name", "type", "file", "nodegroups", "nodes"}, listings
+}
+
+func listNodegroups(config *enc.Config) ([]string, []listing) {
+	listings := make([]listing, 0)
+	for _, currentEnc := range listedENCs(config) {
+		for _, name := range currentEnc.NodegroupNames() {
+			nodegroup := currentEnc.Nodegroups[name]
+
+			if !matchesParent(nodegroup.Parent, currentEnc.Name, *listParent) ||
+				(*listEnvironment != "" && nodegroup.Environment != *listEnvironment) ||
+				(*listEmpty && len(nodegroup.Nodes) != 0) ||
+				(*listOrphaned && !currentEnc.IsOrphaned(name)) {
+				continue
+			}
+
+			entry := nodegroupListing{
+				ENC:         currentEnc.Name,
+				Name:        name,
+				Parent:      nodegroup.Parent,
+				Environment: nodegroup.Environment,
+				Nodes:       nodegroup.Nodes,
+				Classes:     classNames(&nodegroup),
+			}
+
+			listings = append(listings, listing{
+				row:   []string{entry.ENC, entry.Name, entry.Parent, entry.Environment, strconv.Itoa(len(entry.Nodes)), strings.Join(entry.Classes, ",")},
+				value: entry,
+			})
+		}
+	}
+
+	return []string{"enc", "name", "parent", "environment", "nodes", "classes"}, listings
+}
+
+func listNodes(config *enc.Config) ([]string, []listing, error) {
+	listings := make([]listing, 0)
+	for _, currentEnc := range listedENCs(config) {
+		for _, name := range currentEnc.NodeNames() {
+			nodegroups := currentEnc.NodeNodegroups(name)
+			if !nodeMatchesFilters(currentEnc, nodegroups) {
+				continue
+			}
+
+			node, err := currentEnc.GetNode(name)
+			if err != nil {
+				return nil, nil, fmt.Errorf("Could not classify node %s in %s: %s", name, currentEnc.Name, err)
+			}
+
+			if *listEnvironment != "" && node.Environment != *listEnvironment {
+				continue
+			}
+
+			entry := nodeListing{
+				ENC:         currentEnc.Name,
+				Name:        name,
+				Environment: node.Environment,
+				Nodegroups:  nodegroups,
+			}
+
+			listings = append(listings, listing{
+				row:   []string{entry.ENC, entry.Name, entry.Environment, strings.Join(entry.Nodegroups, ",")},
+				value: entry,
+			})
+		}
+	}
+
+	return []string{"enc", "name", "environment", "nodegroups"}, listings, nil
+}
+
+// nodeMatchesFilters applies --nodegroup, --parent and --orphaned to a node's direct nodegroups
+func nodeMatchesFilters(currentEnc *enc.ENC, nodegroups []string) bool {
+	var inNodegroup, hasParent, orphaned bool
+	for _, name := range nodegroups {
+		inNodegroup = inNodegroup || name == *listNodegroup
+		hasParent = hasParent || matchesParent(currentEnc.Nodegroups[name].Parent, currentEnc.Name, *listParent)
+		orphaned = orphaned || currentEnc.IsOrphaned(name)
+	}
+
+	return (*listNodegroup == "" || inNodegroup) && hasParent && (!*listOrphaned || orphaned)
+}
+
+func listClasses(config *enc.Config) ([]string, []listing) {
+	listings := make([]listing, 0)
+	for _, currentEnc := range listedENCs(config) {
+		for _, class := range currentEnc.ClassNames() {
+			nodegroups := currentEnc.ClassNodegroups(class)
+			if *listNodegroup != "" && !containsString(nodegroups, *listNodegroup) {
+				continue
+			}
+
+			entry := classListing{ENC: currentEnc.Name, Name: class, Nodegroups: nodegroups}
+			listings = append(listings, listing{
+				row:   []string{entry.ENC, entry.Name, strings.Join(entry.Nodegroups, ",")},
+				value: entry,
+			})
+		}
+	}
+
+	return []string{"enc", "name", "nodegroups"}, listings
+}
+
+// matchesParent checks a nodegroup's parent against the --parent filter. A filter without an
+// "@cluster" suffix matches that nodegroup name in any ENC.
+func matchesParent(parent string, encName string, filter string) bool {
+	if filter == "" {
+		return true
+	}
+
+	if strings.Contains(filter, "@") {
+		return parent != "" && enc.QualifyNodegroup(parent, encName) == filter
+	}
+
+	return strings.Split(parent, "@")[0] == filter
+}
+
+// sortListings orders listings by the named column, numerically when the column holds numbers.
+// Without a column the listings keep their ENC then name order, reversed if asked.
+func sortListings(headers []string, listings []listing, column string, reverse bool) error {
+	if column == "" {
+		if reverse {
+			for i, j := 0, len(listings)-1; i < j; i, j = i+1, j-1 {
+				listings[i], listings[j] = listings[j], listings[i]
+			}
+		}
+		return nil
+	}
+
+	index := -1
+	for i, header := range headers {
+		if header == column {
+			index = i
+		}
+	}
+
+	if index == -1 {
+		return fmt.Errorf("Cannot sort by %s, expecting one of: %s", column, strings.Join(headers, "|"))
+	}
+
+	sort.Stable(listingsByColumn{listings: listings, index: index, reverse: reverse})
+
+	return nil
+}
+
+type listingsByColumn struct {
+	listings []listing
+	index    int
+	reverse  bool
+}
+
+func (l listingsByColumn) Len() int      { return len(l.listings) }
+func (l listingsByColumn) Swap(i, j int) { l.listings[i], l.listings[j] = l.listings[j], l.listings[i] }
+func (l listingsByColumn) Less(i, j int) bool {
+	a, b := l.listings[i].row[l.index], l.listings[j].row[l.index]
+	if l.reverse {
+		a, b = b, a
+	}
+
+	aNum, aErr := strconv.Atoi(a)
+	bNum, bErr := strconv.Atoi(b)
+	if aErr == nil && bErr == nil {
+		return aNum < bNum
+	}
+
+	return a < b
+}
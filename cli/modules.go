@@ -0,0 +1,52 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/thejokersthief/go-enc/enc"
+)
+
+func modulesClassesCommand(config *enc.Config) {
+	requireCatalog(config)
+
+	names := make([]string, 0, len(config.PuppetCatalog))
+	for name := range config.PuppetCatalog {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	classes := make([]enc.PuppetClass, 0, len(names))
+	rows := make([][]string, 0)
+	for _, name := range names {
+		class := config.PuppetCatalog[name]
+		classes = append(classes, class)
+
+		if len(class.Parameters) == 0 {
+			rows = append(rows, []string{class.Name, "", "", ""})
+		}
+		for _, parameter := range class.Parameters {
+			rows = append(rows, []string{class.Name, parameter.Name, parameter.Type, parameter.Default})
+		}
+	}
+
+	commandErr = printOutput(*modulesClassesOutput, []string{"class", "parameter", "type", "default"}, rows, classes)
+}
+
+func modulesSchemasCommand(config *enc.Config) {
+	requireCatalog(config)
+
+	if commandErr = config.PuppetCatalog.WriteSchemas(*modulesSchemasDir, *modulesSchemasRequired); commandErr != nil {
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "Wrote %d class schemas to %s\n", len(config.PuppetCatalog), *modulesSchemasDir)
+}
+
+func requireCatalog(config *enc.Config) {
+	if config.PuppetCatalog == nil {
+		handleErr(errors.New("Set --modules_dir (or GO_ENC_MODULES_DIR) to the Puppet modules directory"))
+	}
+}
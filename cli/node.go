@@ -0,0 +1,74 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/thejokersthief/go-enc/enc"
+)
+
+// lookupNode classifies a node from --enc_name when it was given, otherwise from every ENC
+func lookupNode(config *enc.Config, nodeName string) (*enc.NodeLookup, error) {
+	if !encNameByUser {
+		return config.LookupNode(nodeName, *node_conflict)
+	}
+
+	working_enc, err := config.GetENC(*enc_name)
+	if err != nil {
+		return &enc.NodeLookup{}, err
+	}
+
+	nodegroup, err := working_enc.GetNode(nodeName)
+	if err != nil {
+		return &enc.NodeLookup{}, err
+	}
+
+	return &enc.NodeLookup{Node: nodeName, ENCs: []string{working_enc.Name}, Nodegroup: nodegroup}, nil
+}
+
+func nodeGetCommand(config *enc.Config) {
+	var lookup *enc.NodeLookup
+	if lookup, commandErr = lookupNode(config, *nodeGetNode); commandErr != nil {
+		return
+	}
+
+	rows := [][]string{{lookup.Node, strings.Join(lookup.ENCs, ","), lookup.Nodegroup.Environment, strings.Join(classNames(lookup.Nodegroup), ",")}}
+	commandErr = printOutput(*nodeGetOutput, []string{"node", "encs", "environment", "classes"}, rows, lookup)
+}
+
+// nodeGroupsCommand explains which nodegroups a node belongs to, in every ENC
+func nodeGroupsCommand(config *enc.Config) {
+	var memberships []enc.Membership
+	if memberships, commandErr = config.NodeMemberships(*nodeGroupsNode); commandErr != nil {
+		return
+	}
+
+	rows := make([][]string, 0, len(memberships))
+	for _, membership := range memberships {
+		rows = append(rows, []string{membership.ENC, membership.Nodegroup, membership.Source, strings.Join(membership.Path, " > ")})
+	}
+
+	commandErr = printOutput(*nodeGroupsOutput, []string{"enc", "nodegroup", "source", "path"}, rows, memberships)
+}
+
+// nodeMoveCommand moves a node between nodegroups, which can be in different ENCs, writing the
+// change out once
+func nodeMoveCommand(config *enc.Config) {
+	var move *enc.NodeMove
+	if move, commandErr = config.MoveNode(*nodeMoveNode, enc.QualifyNodegroup(*nodeMoveFrom, *enc_name), enc.QualifyNodegroup(*nodeMoveTo, *enc_name)); commandErr != nil {
+		return
+	}
+
+	if commandErr = printChanges(*nodeMoveOutput, move.Changes, move); commandErr != nil {
+		return
+	}
+
+	if *nodeMoveDryRun {
+		fmt.Fprintf(os.Stderr, "Dry run, would move %s from %s to %s\n", move.Node, move.From, move.To)
+		return
+	}
+
+	config.WriteOutENC()
+	fmt.Fprintf(os.Stderr, "Moved %s from %s to %s\n", move.Node, move.From, move.To)
+}
@@ -0,0 +1,56 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"gopkg.in/yaml.v2"
+)
+
+// printTable writes rows as aligned columns under upper-cased headers
+func printTable(headers []string, rows [][]string) {
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, strings.ToUpper(strings.Join(headers, "\t")))
+	for _, row := range rows {
+		fmt.Fprintln(writer, strings.Join(row, "\t"))
+	}
+	writer.Flush()
+}
+
+// printStructured writes v as JSON or YAML
+func printStructured(format string, v interface{}) error {
+	var (
+		contents []byte
+		err      error
+	)
+
+	switch format {
+	case "json":
+		contents, err = json.MarshalIndent(v, "", "  ")
+		contents = append(contents, '\n')
+	case "yaml":
+		contents, err = yaml.Marshal(v)
+	default:
+		return fmt.Errorf("Unrecognised output format, expecting: json|yaml: %s", format)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	_, err = os.Stdout.Write(contents)
+	return err
+}
+
+// printOutput writes rows as a table, or v as JSON/YAML, depending on format
+func printOutput(format string, headers []string, rows [][]string, v interface{}) error {
+	if format == "table" {
+		printTable(headers, rows)
+		return nil
+	}
+
+	return printStructured(format, v)
+}
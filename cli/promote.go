@@ -0,0 +1,49 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/thejokersthief/go-enc/enc"
+)
+
+func promoteCommand(config *enc.Config) {
+	var working_enc *enc.ENC
+	if working_enc, commandErr = config.GetENC(*enc_name); commandErr != nil {
+		return
+	}
+
+	nodes := []string{}
+	for _, node := range *promoteNodes {
+		nodes = append(nodes, strings.Split(node, ",")...)
+	}
+
+	var promotion *enc.Promotion
+	if promotion, commandErr = working_enc.Promote(*promoteNodegroup, *promoteEnv, nodes, *promoteChild); commandErr != nil {
+		return
+	}
+
+	if commandErr = printChanges(*promoteOutput, promotion.Changes, promotion); commandErr != nil {
+		return
+	}
+
+	// Like sync, what was done goes to stderr so stdout stays parseable
+	if *promoteDryRun {
+		fmt.Fprintf(os.Stderr, "Dry run, would move %s from %q to %q\n", promotion.Nodegroup, promotion.From, promotion.To)
+		return
+	}
+
+	config.WriteOutENC()
+	fmt.Fprintf(os.Stderr, "Moved %s from %q to %q\n", promotion.Nodegroup, promotion.From, promotion.To)
+}
+
+// printChanges writes the classification changes as a table, or v as JSON/YAML
+func printChanges(format string, changes []enc.ClassificationChange, v interface{}) error {
+	rows := make([][]string, 0, len(changes))
+	for _, change := range changes {
+		rows = append(rows, []string{change.Node, change.Field, change.Change, change.Before, change.After})
+	}
+
+	return printOutput(format, []string{"node", "field", "change", "before", "after"}, rows, v)
+}
@@ -0,0 +1,44 @@
+package cli
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/thejokersthief/go-enc/enc"
+)
+
+func queryCommand(config *enc.Config) {
+	var results []enc.QueryResult
+
+	switch *queryTarget {
+	case "nodes":
+		results, commandErr = config.QueryNodes(*queryExpression)
+	case "nodegroups":
+		results, commandErr = config.QueryNodegroups(*queryExpression)
+	default:
+		handleErr(fmt.Errorf("Invalid target for command: [command: %s ; target: %s]", query.FullCommand(), *queryTarget))
+	}
+
+	if commandErr != nil {
+		return
+	}
+
+	rows := make([][]string, 0, len(results))
+	for _, result := range results {
+		rows = append(rows, []string{result.ENC, result.Name, result.Nodegroup.Environment, strings.Join(classNames(result.Nodegroup), ",")})
+	}
+
+	commandErr = printOutput(*queryOutput, []string{"enc", "name", "environment", "classes"}, rows, results)
+}
+
+// classNames returns the sorted names of the classes applied to a nodegroup
+func classNames(nodegroup *enc.Nodegroup) []string {
+	names := make([]string, 0, len(nodegroup.Classes))
+	for name := range nodegroup.Classes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
@@ -3,6 +3,7 @@ package cli
 import (
 	"fmt"
 	"os"
+	"strings"
 
 	"gopkg.in/alecthomas/kingpin.v2"
 
@@ -10,21 +11,53 @@ import (
 )
 
 var (
-	app = kingpin.New("go-enc", "CLI for interacting with YAML/JSON External Node Classifiers")
+	app = kingpin.New("go-enc", "CLI for interacting with YAML/JSON/TOML/HCL External Node Classifiers")
 
 	enc_glob = app.Flag("enc_glob", "Glob pattern for matching ENC files").Default("./*.yaml").Short('g').String()
-	enc_name = app.Flag("enc_name", "Name of the ENC you want to perform actions on").Default("production").Short('e').String()
+	enc_name = app.Flag("enc_name", "Name of the ENC you want to perform actions on").Default("production").Short('e').Action(setEncNameByUser).String()
 
-	nodegroup       = app.Command("nodegroup", "Actions to do with nodegroups")
-	nodegroupAction = nodegroup.Arg("action", "add|remove|get").Required().String()
-	nodegroupName   = nodegroup.Arg("nodegroup", "Nodegoup name").Required().String()
-	nodegroupParent = nodegroup.Flag("parent", "Nodegoup parent").Default("").String()
+	enc_precedence = StringList(app.Flag("enc_precedence", "ENC names, highest first, deciding which answers for a node found in several ENCs (repeatable or comma-separated)"))
+	node_conflict  = app.Flag("node_conflict", "What to do when a node is found in several ENCs: error|first|merge").Default("error").String()
+	key_dir        = app.Flag("key_dir", "Directory of secret key files, to decrypt secrets when classifying and to encrypt them").Envar("GO_ENC_KEY_DIR").Default("").String()
+	schema_dir     = app.Flag("schema_dir", "Directory of JSON Schema files for class parameters, named after the class (nginx__vhost.json)").Envar("GO_ENC_SCHEMA_DIR").Default("").String()
+	modules_dir    = app.Flag("modules_dir", "Puppet modules directory, to warn about classes and class parameters its manifests don't define").Envar("GO_ENC_MODULES_DIR").Default("").String()
+	environments   = StringList(app.Flag("environments", "Environments nodegroups can be in (repeatable or comma-separated), any if neither this nor --environments_dir is given"))
+	environmentDir = app.Flag("environments_dir", "Directory whose subdirectories are the environments nodegroups can be in, e.g. a Puppet code directory's environments/").Envar("GO_ENC_ENVIRONMENTS_DIR").Default("").String()
+	strict         = app.Flag("strict", "Refuse to load ENCs whose nodegroups have keys go-enc doesn't know about").Bool()
 
-	node          = app.Command("node", "Actions to do with single node")
-	nodeAction    = node.Arg("action", "add|remove|get").Required().String()
-	nodeNodegroup = node.Arg("nodegroup", "Nodegoup name").Required().String()
-	nodeNode      = node.Arg("node", "Node").Required().String()
-	nodeOutput    = node.Flag("output", "Output format: json|yaml").Default("yaml").Short('o').String()
+	nodegroup          = app.Command("nodegroup", "Actions to do with nodegroups")
+	nodegroupAction    = nodegroup.Arg("action", "add|remove|get|rename|copy|merge").Required().String()
+	nodegroupName      = nodegroup.Arg("nodegroup", "Nodegoup name").Required().String()
+	nodegroupTarget    = nodegroup.Arg("to", "For rename and copy, the new name; for merge, the nodegroup to merge into").Default("").String()
+	nodegroupParent    = nodegroup.Flag("parent", "Nodegoup parent").Default("").String()
+	nodegroupWithNodes = nodegroup.Flag("with-nodes", "For copy, also add the nodes to the copy").Bool()
+	nodegroupOutput    = nodegroup.Flag("output", "For merge, output format of the classification changes: table|json|yaml").Default("table").Short('o').String()
+	nodegroupDryRun    = nodegroup.Flag("dry-run", "For merge, only show how the classification of nodes would change").Bool()
+
+	node = app.Command("node", "Actions to do with single node")
+
+	nodeAdd          = node.Command("add", "Add a node to a nodegroup")
+	nodeAddNodegroup = nodeAdd.Arg("nodegroup", "Nodegoup name").Required().String()
+	nodeAddNode      = nodeAdd.Arg("node", "Node certname").Required().String()
+
+	nodeRemove          = node.Command("remove", "Remove a node from a nodegroup")
+	nodeRemoveNodegroup = nodeRemove.Arg("nodegroup", "Nodegoup name").Required().String()
+	nodeRemoveNode      = nodeRemove.Arg("node", "Node certname").Required().String()
+
+	nodeGet       = node.Command("get", "Print the merged classification of a node and the ENCs it came from")
+	nodeGetNode   = nodeGet.Arg("node", "Node certname").Required().String()
+	nodeGetOutput = nodeGet.Flag("output", "Output format: table|json|yaml").Default("yaml").Short('o').String()
+
+	nodeGroups       = node.Command("groups", "List the nodegroups a node belongs to in every ENC, and why")
+	nodeGroupsNode   = nodeGroups.Arg("node", "Node certname").Required().String()
+	nodeGroupsOutput = nodeGroups.Flag("output", "Output format: table|json|yaml").Default("yaml").Short('o').String()
+
+	nodeMove       = node.Command("move", "Move a node between nodegroups, which can be in different ENCs, in one write")
+	nodeMoveNode   = nodeMove.Arg("node", "Node certname").Required().String()
+	nodeMoveFrom   = nodeMove.Arg("from", "Nodegroup to move from, as name or name@cluster").Required().String()
+	nodeMoveTo     = nodeMove.Arg("to", "Nodegroup to move to, as name or name@cluster").Required().String()
+	nodeMoveOutput = nodeMove.Flag("output", "Output format: table|json|yaml").Default("table").Short('o').String()
+	nodeMoveDryRun = nodeMove.Flag("dry-run", "Only show how the node's classification would change").Bool()
 
 	nodes          = app.Command("nodes", "Actions to do with single node")
 	nodesAdd       = nodes.Arg("add", "add").Required().String()
@@ -33,10 +66,11 @@ var (
 	nodesOutput    = nodes.Flag("output", "Output format: json|yaml").Default("yaml").Short('o').String()
 
 	param          = app.Command("param", "Actions for parameters")
-	paramAction    = param.Arg("action", "add|set|remove").Required().String()
+	paramAction    = param.Arg("action", "add|set|append|remove").Required().String()
 	paramNodegroup = param.Arg("nodegroup", "Nodegoup name").Required().String()
-	paramName      = param.Arg("param_name", "Parameter name").Required().String()
+	paramName      = param.Arg("param_name", "Parameter name, or a path to a nested value like nginx.upstreams[0].port").Required().String()
 	paramValue     = param.Arg("param_value", "Parameter value").Required().String()
+	paramType      = param.Flag("type", "How to read param_value, auto reading numbers, booleans, null and JSON and leaving anything else a string: auto|string|int|float|bool|json|yaml").Default("auto").Short('t').String()
 
 	class          = app.Command("class", "Actions for classes")
 	classAction    = class.Arg("action", "add|remove").Required().String()
@@ -44,11 +78,12 @@ var (
 	className      = class.Arg("classname", "Class name").Required().String()
 
 	classParam          = app.Command("class_param", "Actions for parameters")
-	classParamAction    = classParam.Arg("action", "add|set|remove").Required().String()
+	classParamAction    = classParam.Arg("action", "add|set|append|remove").Required().String()
 	classParamNodegroup = classParam.Arg("nodegroup", "Nodegoup name").Required().String()
 	classParamClass     = classParam.Arg("class_name", "Class name").Required().String()
-	classParamName      = classParam.Arg("param_name", "Parameter name").Required().String()
+	classParamName      = classParam.Arg("param_name", "Parameter name, or a path to a nested value like upstreams[0].port").Required().String()
 	classParamValue     = classParam.Arg("param_value", "Parameter value").Required().String()
+	classParamType      = classParam.Flag("type", "How to read param_value, auto reading numbers, booleans, null and JSON and leaving anything else a string: auto|string|int|float|bool|json|yaml").Default("auto").Short('t').String()
 
 	parent          = app.Command("parent", "Set the parent value")
 	parentNodegroup = parent.Arg("nodegroup", "Nodegoup name").Required().String()
@@ -58,13 +93,271 @@ var (
 	environmentNodegroup = environment.Arg("nodegroup", "Nodegoup name").Required().String()
 	environmentVal       = environment.Arg("new_environment", "The new environment value (can be \"\" for none)").Required().String()
 
+	promote          = app.Command("promote", "Move a nodegroup of the ENC chosen with --enc_name, or some of its nodes, to another environment")
+	promoteNodegroup = promote.Arg("nodegroup", "Nodegoup name").Required().String()
+	promoteEnv       = promote.Arg("environment", "Environment to move to").Required().String()
+	promoteNodes     = StringList(promote.Flag("nodes", "Only move these nodes, into a child nodegroup in the new environment (repeatable or comma-separated)"))
+	promoteChild     = promote.Flag("child", "Name of the child nodegroup for --nodes, defaults to <nodegroup>_<environment>").Default("").String()
+	promoteDryRun    = promote.Flag("dry-run", "Only show how the classification of nodes would change").Bool()
+	promoteOutput    = promote.Flag("output", "Output format: table|json|yaml").Default("table").Short('o').String()
+
+	canaryCmd = app.Command("canary", "Try changes on some of a nodegroup's nodes, in a child nodegroup of the ENC chosen with --enc_name")
+
+	canaryCreate            = canaryCmd.Command("create", "Move some nodes of a nodegroup into a canary child with overriding parameters or environment")
+	canaryCreateNodegroup   = canaryCreate.Arg("nodegroup", "Nodegoup name").Required().String()
+	canaryCreateName        = canaryCreate.Flag("name", "Name of the canary nodegroup, defaults to <nodegroup>_canary").Default("").String()
+	canaryCreateCount       = canaryCreate.Flag("count", "Number of nodes to move into the canary").Default("0").Int()
+	canaryCreatePercent     = canaryCreate.Flag("percent", "Percentage of nodes to move into the canary, rounded up").Default("0").Float64()
+	canaryCreateParams      = StringList(canaryCreate.Flag("param", "Parameter to override, as path=value (repeatable)"))
+	canaryCreateType        = canaryCreate.Flag("type", "How to read the --param values, auto reading numbers, booleans, null and JSON and leaving anything else a string: auto|string|int|float|bool|json|yaml").Default("auto").Short('t').String()
+	canaryCreateEnvironment = canaryCreate.Flag("environment", "Environment to override").Default("").String()
+
+	canaryPromote     = canaryCmd.Command("promote", "Merge the overrides of a canary into its parent, move its nodes back and remove it")
+	canaryPromoteName = canaryPromote.Arg("canary", "Canary nodegroup name").Required().String()
+
+	canaryAbort     = canaryCmd.Command("abort", "Move the nodes of a canary back to its parent and remove it")
+	canaryAbortName = canaryAbort.Arg("canary", "Canary nodegroup name").Required().String()
+
+	canaryDryRun = canaryCmd.Flag("dry-run", "Only show how the classification of nodes would change").Bool()
+	canaryOutput = canaryCmd.Flag("output", "Output format: table|json|yaml").Default("table").Short('o').String()
+
+	query           = app.Command("query", "Search nodes or nodegroups across all ENCs with a filter expression")
+	queryExpression = query.Arg("expression", "Filter, e.g. \"class.nginx.worker_processes > 4 and environment = production\"").Default("").String()
+	queryTarget     = query.Flag("target", "What to search: nodes|nodegroups").Default("nodes").Short('t').String()
+	queryOutput     = query.Flag("output", "Output format: table|json|yaml").Default("table").Short('o').String()
+
+	list            = app.Command("list", "List ENCs, nodegroups, nodes or classes across all ENCs")
+	listKind        = list.Arg("kind", "encs|nodegroups|nodes|classes").Required().String()
+	listENC         = list.Flag("enc", "Only list from this ENC").Default("").String()
+	listParent      = list.Flag("parent", "Only list nodegroups (or their nodes) with this parent").Default("").String()
+	listNodegroup   = list.Flag("nodegroup", "Only list nodes or classes of this nodegroup").Default("").String()
+	listEnvironment = list.Flag("environment", "Only list nodegroups or nodes in this environment").Default("").String()
+	listOrphaned    = list.Flag("orphaned", "Only list nodegroups or nodes whose parent chain is broken").Bool()
+	listEmpty       = list.Flag("empty", "Only list nodegroups with no nodes").Bool()
+	listSort        = list.Flag("sort", "Column to sort by, instead of ENC then name").Default("").String()
+	listReverse     = list.Flag("reverse", "Reverse the sort order").Bool()
+	listOutput      = list.Flag("output", "Output format: table|json|yaml").Default("table").Short('o').String()
+
+	tree       = app.Command("tree", "Show the nodegroup hierarchy across all ENCs")
+	treeRoot   = tree.Arg("nodegroup", "Only show this nodegroup (name or name@cluster) and its children").Default("").String()
+	treeFormat = tree.Flag("format", "Output format: ascii|dot|mermaid").Default("ascii").Short('f').String()
+	treeNodes  = tree.Flag("nodes", "Include the nodes of each nodegroup").Bool()
+
+	classify       = app.Command("classify", "Print the Puppet classification of a node, searching every ENC unless --enc_name is given")
+	classifyNode   = classify.Arg("node", "Node certname").Required().String()
+	classifyOutput = classify.Flag("output", "Output format: json|yaml").Default("yaml").Short('o').String()
+
+	export = app.Command("export", "Export the ENCs for other tools")
+
+	exportAnsible     = export.Command("ansible", "Ansible inventory: static YAML, or a dynamic inventory with --list/--host")
+	exportAnsibleList = exportAnsible.Flag("list", "Print the dynamic inventory JSON for every group").Bool()
+	exportAnsibleHost = exportAnsible.Flag("host", "Print the dynamic inventory JSON for a single host").Default("").String()
+
+	exportHiera       = export.Command("hiera", "Hiera data files with every node's parameters, plus a hiera.yaml snippet")
+	exportHieraDir    = exportHiera.Flag("dir", "Directory to write the data files to").Required().String()
+	exportHieraGroups = exportHiera.Flag("groups", "Also write the parameters of each nodegroup to groups/<nodegroup>.yaml").Bool()
+
+	exportSalt    = export.Command("salt", "Salt state and pillar top files, with a pillar SLS per nodegroup")
+	exportSaltDir = exportSalt.Flag("dir", "Directory to write salt/ and pillar/ to").Required().String()
+
+	exportPrometheusSD      = export.Command("prometheus-sd", "Prometheus file_sd_configs JSON with a target group per nodegroup")
+	exportPrometheusSDParam = StringList(exportPrometheusSD.Flag("param", "Parameter (or dotted path) to add as a label (repeatable or comma-separated)"))
+	exportPrometheusSDPort  = exportPrometheusSD.Flag("port", "Port to append to every target").Default("0").Int()
+
+	convert          = app.Command("convert", "Write the ENC chosen with --enc_name in another file format")
+	convertFormat    = convert.Arg("format", "json|yaml|toml|hcl").Required().String()
+	convertFile      = convert.Flag("file", "File to write, defaults to the ENC's file with the new extension").Default("").String()
+	convertOverwrite = convert.Flag("overwrite", "Replace the file if it already exists").Bool()
+
+	validate       = app.Command("validate", "Check the class parameters of every nodegroup and node against the class schemas in --schema_dir")
+	validateOutput = validate.Flag("output", "Output format: table|json|yaml").Default("table").Short('o').String()
+
+	modulesCmd = app.Command("modules", "Classes defined in the Puppet modules in --modules_dir")
+
+	modulesClasses       = modulesCmd.Command("classes", "List every class with its parameters, their types and defaults")
+	modulesClassesOutput = modulesClasses.Flag("output", "Output format: table|json|yaml").Default("table").Short('o').String()
+
+	modulesSchemas         = modulesCmd.Command("schemas", "Write a class schema for every class, for use with --schema_dir")
+	modulesSchemasDir      = modulesSchemas.Flag("dir", "Directory to write the schemas to").Required().String()
+	modulesSchemasRequired = modulesSchemas.Flag("required", "Require parameters without a default, for classes whose parameters all come from the ENC rather than Hiera").Bool()
+
+	terraform = app.Command("terraform", "Terraform external data source: reads a JSON query with a node or nodegroup on stdin, prints its flattened parameters")
+
+	syncCmd = app.Command("sync", "Compare the ENCs with real machines")
+
+	syncNodes                  = syncCmd.Command("nodes", "Report classified nodes that no longer exist and machines in no nodegroup")
+	syncNodesFile              = syncNodes.Flag("file", "PuppetDB nodes export (JSON) or a list of certnames, one per line").Required().String()
+	syncNodesAddTo             = syncNodes.Flag("add-to", "Add unclassified nodes to this nodegroup of the ENC chosen with --enc_name").Default("").String()
+	syncNodesRemoveDeactivated = syncNodes.Flag("remove-deactivated", "Remove nodes deactivated in PuppetDB from every nodegroup").Bool()
+	syncNodesOutput            = syncNodes.Flag("output", "Output format: table|json|yaml").Default("table").Short('o').String()
+
+	importCmd = app.Command("import", "Import nodegroups from other tools into the ENC chosen with --enc_name")
+
+	importForeman           = importCmd.Command("foreman", "Foreman host group and host JSON exports")
+	importForemanHostgroups = importForeman.Flag("hostgroups", "File with the host group export, e.g. from /api/hostgroups").Required().String()
+	importForemanHosts      = importForeman.Flag("hosts", "File with the host export, e.g. from /api/hosts").Default("").String()
+	importForemanOutput     = importForeman.Flag("output", "Report format: json|yaml").Default("yaml").Short('o').String()
+
+	secretCmd = app.Command("secret", "Encrypted secret parameters, using the key files in --key_dir")
+
+	secretKeygen     = secretCmd.Command("keygen", "Create a new key file in --key_dir")
+	secretKeygenName = secretKeygen.Arg("key", "Key name, used for the file name").Required().String()
+
+	secretEncrypt          = secretCmd.Command("encrypt", "Encrypt a parameter of the ENC chosen with --enc_name in place")
+	secretEncryptNodegroup = secretEncrypt.Arg("nodegroup", "Nodegoup name").Required().String()
+	secretEncryptName      = secretEncrypt.Arg("param_name", "Parameter name or path").Required().String()
+	secretEncryptClass     = secretEncrypt.Flag("class", "Encrypt a parameter of this class instead").Default("").String()
+	secretEncryptKey       = secretEncrypt.Flag("key", "Name of the key to encrypt with").Required().String()
+
+	secretSet          = secretCmd.Command("set", "Set an encrypted parameter, so the value is never written out in plain text")
+	secretSetNodegroup = secretSet.Arg("nodegroup", "Nodegoup name").Required().String()
+	secretSetName      = secretSet.Arg("param_name", "Parameter name or path").Required().String()
+	secretSetValue     = secretSet.Arg("param_value", "Parameter value").Required().String()
+	secretSetClass     = secretSet.Flag("class", "Set a parameter of this class instead").Default("").String()
+	secretSetKey       = secretSet.Flag("key", "Name of the key to encrypt with").Required().String()
+	secretSetType      = secretSet.Flag("type", "How to read param_value, auto reading numbers, booleans, null and JSON and leaving anything else a string: auto|string|int|float|bool|json|yaml").Default("auto").Short('t').String()
+
+	secretRotate     = secretCmd.Command("rotate", "Re-encrypt every secret in the ENC chosen with --enc_name with another key")
+	secretRotateKey  = secretRotate.Flag("key", "Name of the key to encrypt with").Required().String()
+	secretRotateFrom = secretRotate.Flag("from", "Only re-encrypt secrets using this key").Default("").String()
+
+	// encNameByUser is true when --enc_name was passed rather than defaulted, so node lookups
+	// should only use that ENC
+	encNameByUser bool
+
 	commandErr error
 )
 
+func setEncNameByUser(*kingpin.ParseContext) error {
+	encNameByUser = true
+	return nil
+}
+
 func NewCLI() {
 	arguments := kingpin.MustParse(app.Parse(os.Args[1:]))
 
 	config := enc.NewConfig(*enc_glob)
+	for _, precedence := range *enc_precedence {
+		config.Precedence = append(config.Precedence, strings.Split(precedence, ",")...)
+	}
+	if *strict {
+		handleErr(config.CheckStrict())
+	}
+	if *key_dir != "" {
+		keys, err := enc.LoadSecretKeys(*key_dir)
+		handleErr(err)
+		config.SecretKeys = keys
+	}
+	if *schema_dir != "" {
+		schemas, err := enc.LoadClassSchemas(*schema_dir)
+		handleErr(err)
+		config.ClassSchemas = schemas
+	}
+	for _, env := range *environments {
+		config.Environments = append(config.Environments, strings.Split(env, ",")...)
+	}
+	if *environmentDir != "" {
+		envs, err := enc.LoadEnvironments(*environmentDir)
+		handleErr(err)
+		config.Environments = append(config.Environments, envs...)
+	}
+	if *modules_dir != "" {
+		catalog, err := enc.ScanPuppetModules(*modules_dir)
+		handleErr(err)
+		config.PuppetCatalog = catalog
+	}
+
+	// Read-only commands work across every ENC and never write the files back out, sync only
+	// does when asked to change something
+	switch arguments {
+	case query.FullCommand():
+		queryCommand(config)
+		handleErr(commandErr)
+		return
+	case list.FullCommand():
+		listCommand(config)
+		handleErr(commandErr)
+		return
+	case tree.FullCommand():
+		treeCommand(config)
+		handleErr(commandErr)
+		return
+	case nodeGroups.FullCommand():
+		nodeGroupsCommand(config)
+		handleErr(commandErr)
+		return
+	case nodeGet.FullCommand():
+		nodeGetCommand(config)
+		handleErr(commandErr)
+		return
+	case nodeMove.FullCommand():
+		nodeMoveCommand(config)
+		handleErr(commandErr)
+		return
+	case classify.FullCommand():
+		classifyCommand(config)
+		handleErr(commandErr)
+		return
+	case exportAnsible.FullCommand():
+		exportAnsibleCommand(config)
+		handleErr(commandErr)
+		return
+	case exportHiera.FullCommand():
+		exportHieraCommand(config)
+		handleErr(commandErr)
+		return
+	case exportSalt.FullCommand():
+		exportSaltCommand(config)
+		handleErr(commandErr)
+		return
+	case exportPrometheusSD.FullCommand():
+		exportPrometheusSDCommand(config)
+		handleErr(commandErr)
+		return
+	case convert.FullCommand():
+		convertCommand(config)
+		handleErr(commandErr)
+		return
+	case validate.FullCommand():
+		validateCommand(config)
+		handleErr(commandErr)
+		return
+	case modulesClasses.FullCommand():
+		modulesClassesCommand(config)
+		handleErr(commandErr)
+		return
+	case modulesSchemas.FullCommand():
+		modulesSchemasCommand(config)
+		handleErr(commandErr)
+		return
+	case terraform.FullCommand():
+		terraformCommand(config)
+		handleErr(commandErr)
+		return
+	case syncNodes.FullCommand():
+		syncNodesCommand(config)
+		handleErr(commandErr)
+		return
+	case promote.FullCommand():
+		promoteCommand(config)
+		handleErr(commandErr)
+		return
+	case nodegroup.FullCommand():
+		if *nodegroupAction == "merge" {
+			nodegroupMergeCommand(config)
+			handleErr(commandErr)
+			return
+		}
+	case canaryCreate.FullCommand(), canaryPromote.FullCommand(), canaryAbort.FullCommand():
+		canaryCommand(config, arguments)
+		handleErr(commandErr)
+		return
+	case secretKeygen.FullCommand():
+		secretKeygenCommand()
+		handleErr(commandErr)
+		return
+	}
+
 	working_enc, ok := config.ENCs[*enc_name]
 	if !ok {
 		handleErr(fmt.Errorf("Chosen ENC doesn't exist: %s", *enc_name))
@@ -73,8 +366,8 @@ func NewCLI() {
 	switch arguments {
 	case nodegroup.FullCommand():
 		nodegroupCommand(working_enc)
-	case node.FullCommand():
-		nodeCommand(working_enc)
+	case nodeAdd.FullCommand(), nodeRemove.FullCommand():
+		nodeCommand(working_enc, arguments)
 	case nodes.FullCommand():
 		nodesCommand(working_enc)
 	case param.FullCommand():
@@ -87,8 +380,17 @@ func NewCLI() {
 		parentCommand(working_enc)
 	case environment.FullCommand():
 		environmentCommand(working_enc)
+	case importForeman.FullCommand():
+		importForemanCommand(working_enc)
+	case secretEncrypt.FullCommand():
+		secretEncryptCommand(config, working_enc)
+	case secretSet.FullCommand():
+		secretSetCommand(config, working_enc)
+	case secretRotate.FullCommand():
+		secretRotateCommand(config, working_enc)
 	}
 
+	printWarnings(config)
 	handleErr(commandErr)
 
 	config.WriteOutENC()
@@ -102,21 +404,71 @@ func nodegroupCommand(working_enc *enc.ENC) {
 		_, commandErr = working_enc.RemoveNodegroup(*nodegroupName)
 	case "get":
 		_, commandErr = working_enc.GetNodegroup(*nodegroupName)
+	case "rename", "copy":
+		nodegroupTargetCommand(working_enc)
 	default:
 		handleErr(fmt.Errorf("Invalid action for command: [command: %s ; action: %s]", nodegroup.FullCommand(), *nodegroupAction))
 	}
 }
 
-func nodeCommand(working_enc *enc.ENC) {
-	switch *nodeAction {
-	case "add":
-		_, commandErr = working_enc.AddNode(*nodeNodegroup, *nodeNode)
-	case "get":
-		_, commandErr = working_enc.GetNode(*nodeNode)
-	case "remove":
-		_, commandErr = working_enc.RemoveNode(*nodeNodegroup, *nodeNode)
-	default:
-		handleErr(fmt.Errorf("Invalid action for command: [command: %s ; action: %s]", node.FullCommand(), *nodeAction))
+// nodegroupTargetCommand runs the nodegroup actions that need a second nodegroup, reporting the
+// children whose parent changed on stderr
+func nodegroupTargetCommand(working_enc *enc.ENC) {
+	if *nodegroupTarget == "" {
+		handleErr(fmt.Errorf("Missing nodegroup to %s to: [command: %s ; action: %s]", *nodegroupAction, nodegroup.FullCommand(), *nodegroupAction))
+	}
+
+	var children []string
+	switch *nodegroupAction {
+	case "rename":
+		children, commandErr = working_enc.RenameNodegroup(*nodegroupName, *nodegroupTarget)
+	case "copy":
+		_, commandErr = working_enc.CopyNodegroup(*nodegroupName, *nodegroupTarget, *nodegroupWithNodes)
+	}
+
+	if commandErr == nil && len(children) > 0 {
+		fmt.Fprintf(os.Stderr, "Updated the parent of: %s\n", strings.Join(children, ", "))
+	}
+}
+
+// nodegroupMergeCommand merges a nodegroup into another, printing how the classification of nodes
+// changes, and writes it out unless --dry-run
+func nodegroupMergeCommand(config *enc.Config) {
+	var working_enc *enc.ENC
+	if working_enc, commandErr = config.GetENC(*enc_name); commandErr != nil {
+		return
+	}
+	if *nodegroupTarget == "" {
+		handleErr(fmt.Errorf("Missing nodegroup to merge to: [command: %s ; action: %s]", nodegroup.FullCommand(), *nodegroupAction))
+	}
+
+	var merge *enc.NodegroupMerge
+	if merge, commandErr = working_enc.MergeNodegroup(*nodegroupName, *nodegroupTarget); commandErr != nil {
+		return
+	}
+
+	if commandErr = printChanges(*nodegroupOutput, merge.Changes, merge); commandErr != nil {
+		return
+	}
+
+	if *nodegroupDryRun {
+		fmt.Fprintf(os.Stderr, "Dry run, would merge %s into %s\n", merge.Nodegroup, merge.Into)
+		return
+	}
+
+	config.WriteOutENC()
+	if len(merge.Children) > 0 {
+		fmt.Fprintf(os.Stderr, "Updated the parent of: %s\n", strings.Join(merge.Children, ", "))
+	}
+	fmt.Fprintf(os.Stderr, "Merged %s into %s\n", merge.Nodegroup, merge.Into)
+}
+
+func nodeCommand(working_enc *enc.ENC, command string) {
+	switch command {
+	case nodeAdd.FullCommand():
+		_, commandErr = working_enc.AddNode(*nodeAddNodegroup, *nodeAddNode)
+	case nodeRemove.FullCommand():
+		_, commandErr = working_enc.RemoveNode(*nodeRemoveNodegroup, *nodeRemoveNode)
 	}
 }
 
@@ -127,11 +479,19 @@ func nodesCommand(working_enc *enc.ENC) {
 func paramCommand(working_enc *enc.ENC) {
 	switch *paramAction {
 	case "add":
-		_, commandErr = working_enc.AddParameter(*paramNodegroup, *paramName, *paramValue)
+		value, err := enc.ParseValue(*paramValue, *paramType)
+		handleErr(err)
+		_, commandErr = working_enc.AddParameterPath(*paramNodegroup, *paramName, value)
 	case "set":
-		_, commandErr = working_enc.SetParameter(*paramNodegroup, *paramName, *paramValue)
+		value, err := enc.ParseValue(*paramValue, *paramType)
+		handleErr(err)
+		_, commandErr = working_enc.SetParameterPath(*paramNodegroup, *paramName, value)
+	case "append":
+		value, err := enc.ParseValue(*paramValue, *paramType)
+		handleErr(err)
+		_, commandErr = working_enc.AppendParameterPath(*paramNodegroup, *paramName, value)
 	case "remove":
-		_, commandErr = working_enc.RemoveParameter(*paramNodegroup, *paramName)
+		_, commandErr = working_enc.RemoveParameterPath(*paramNodegroup, *paramName)
 	default:
 		handleErr(fmt.Errorf("Invalid action for command: [command: %s ; action: %s]", param.FullCommand(), *paramAction))
 	}
@@ -151,11 +511,19 @@ func classCommand(working_enc *enc.ENC) {
 func classParamCommand(working_enc *enc.ENC) {
 	switch *classParamAction {
 	case "add":
-		_, commandErr = working_enc.AddClassParameter(*classParamNodegroup, *classParamClass, *classParamName, *classParamValue)
+		value, err := enc.ParseValue(*classParamValue, *classParamType)
+		handleErr(err)
+		_, commandErr = working_enc.AddClassParameterPath(*classParamNodegroup, *classParamClass, *classParamName, value)
 	case "set":
-		_, commandErr = working_enc.SetClassParameter(*classParamNodegroup, *classParamClass, *classParamName, *classParamValue)
+		value, err := enc.ParseValue(*classParamValue, *classParamType)
+		handleErr(err)
+		_, commandErr = working_enc.SetClassParameterPath(*classParamNodegroup, *classParamClass, *classParamName, value)
+	case "append":
+		value, err := enc.ParseValue(*classParamValue, *classParamType)
+		handleErr(err)
+		_, commandErr = working_enc.AppendClassParameterPath(*classParamNodegroup, *classParamClass, *classParamName, value)
 	case "remove":
-		_, commandErr = working_enc.RemoveClassParameter(*classParamNodegroup, *classParamClass, *classParamName)
+		_, commandErr = working_enc.RemoveClassParameterPath(*classParamNodegroup, *classParamClass, *classParamName)
 	default:
 		handleErr(fmt.Errorf("Invalid action for command: [command: %s ; action: %s]", classParam.FullCommand(), *classParamAction))
 	}
@@ -0,0 +1,65 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/thejokersthief/go-enc/enc"
+)
+
+func secretKeygenCommand() {
+	if *key_dir == "" {
+		handleErr(errors.New("Set --key_dir (or GO_ENC_KEY_DIR) to the directory for key files"))
+	}
+
+	var fileName string
+	if fileName, commandErr = enc.WriteSecretKey(*key_dir, *secretKeygenName); commandErr != nil {
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "Created %s, keep it out of version control\n", fileName)
+}
+
+// secretKeys returns the keys loaded from --key_dir, which the commands writing secrets need
+func secretKeys(config *enc.Config) enc.SecretKeys {
+	if config.SecretKeys == nil {
+		handleErr(errors.New("Set --key_dir (or GO_ENC_KEY_DIR) to the directory of key files"))
+	}
+
+	return config.SecretKeys
+}
+
+func secretEncryptCommand(config *enc.Config, working_enc *enc.ENC) {
+	keys := secretKeys(config)
+	if *secretEncryptClass != "" {
+		_, commandErr = working_enc.EncryptClassParameterPath(*secretEncryptNodegroup, *secretEncryptClass, *secretEncryptName, keys, *secretEncryptKey)
+	} else {
+		_, commandErr = working_enc.EncryptParameterPath(*secretEncryptNodegroup, *secretEncryptName, keys, *secretEncryptKey)
+	}
+}
+
+func secretSetCommand(config *enc.Config, working_enc *enc.ENC) {
+	keys := secretKeys(config)
+
+	value, err := enc.ParseValue(*secretSetValue, *secretSetType)
+	handleErr(err)
+
+	secret, err := keys.Encrypt(value, *secretSetKey)
+	handleErr(err)
+
+	if *secretSetClass != "" {
+		_, commandErr = working_enc.SetClassParameterPath(*secretSetNodegroup, *secretSetClass, *secretSetName, secret)
+	} else {
+		_, commandErr = working_enc.SetParameterPath(*secretSetNodegroup, *secretSetName, secret)
+	}
+}
+
+func secretRotateCommand(config *enc.Config, working_enc *enc.ENC) {
+	var rotated int
+	if rotated, commandErr = working_enc.RotateSecrets(secretKeys(config), *secretRotateFrom, *secretRotateKey); commandErr != nil {
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "Re-encrypted %d secrets with %s\n", rotated, *secretRotateKey)
+}
@@ -0,0 +1,66 @@
+package cli
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/thejokersthief/go-enc/enc"
+)
+
+func syncNodesCommand(config *enc.Config) {
+	data, err := ioutil.ReadFile(*syncNodesFile)
+	handleErr(err)
+
+	var inventory *enc.NodeInventory
+	if inventory, commandErr = enc.ParseNodeInventory(data); commandErr != nil {
+		return
+	}
+
+	sync := config.SyncNodes(inventory)
+
+	rows := [][]string{}
+	for _, node := range sync.Missing {
+		rows = append(rows, []string{node, "missing"})
+	}
+	for _, node := range sync.Deactivated {
+		rows = append(rows, []string{node, "deactivated"})
+	}
+	for _, node := range sync.Unclassified {
+		rows = append(rows, []string{node, "unclassified"})
+	}
+
+	if commandErr = printOutput(*syncNodesOutput, []string{"node", "status"}, rows, sync); commandErr != nil {
+		return
+	}
+
+	if *syncNodesAddTo == "" && !*syncNodesRemoveDeactivated {
+		return
+	}
+
+	// Changes are reported on stderr so the report on stdout stays parseable
+	if *syncNodesAddTo != "" && len(sync.Unclassified) > 0 {
+		var working_enc *enc.ENC
+		if working_enc, commandErr = config.GetENC(*enc_name); commandErr != nil {
+			return
+		}
+
+		if _, commandErr = working_enc.AddNodes(*syncNodesAddTo, sync.Unclassified); commandErr != nil {
+			return
+		}
+		fmt.Fprintf(os.Stderr, "Added to %s: %s\n", enc.QualifyNodegroup(*syncNodesAddTo, working_enc.Name), strings.Join(sync.Unclassified, ", "))
+	}
+
+	if *syncNodesRemoveDeactivated {
+		for _, node := range sync.Deactivated {
+			var removed []string
+			if removed, commandErr = config.RemoveNodeEverywhere(node); commandErr != nil {
+				return
+			}
+			fmt.Fprintf(os.Stderr, "Removed %s from: %s\n", node, strings.Join(removed, ", "))
+		}
+	}
+
+	config.WriteOutENC()
+}
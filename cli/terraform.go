@@ -0,0 +1,32 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/thejokersthief/go-enc/enc"
+)
+
+func terraformCommand(config *enc.Config) {
+	input, err := ioutil.ReadAll(os.Stdin)
+	handleErr(err)
+
+	query := map[string]string{}
+	if err := json.Unmarshal(input, &query); err != nil {
+		commandErr = fmt.Errorf("Could not read the Terraform query: %s", err)
+		return
+	}
+
+	if _, ok := query["enc"]; !ok && encNameByUser {
+		query["enc"] = *enc_name
+	}
+
+	var result map[string]string
+	if result, commandErr = config.TerraformQuery(query, *node_conflict); commandErr != nil {
+		return
+	}
+
+	commandErr = printStructured("json", result)
+}
@@ -0,0 +1,204 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/thejokersthief/go-enc/enc"
+)
+
+func treeCommand(config *enc.Config) {
+	roots := config.NodegroupTree()
+
+	if *treeRoot != "" {
+		root := findTreeNode(roots, *treeRoot, make(map[string]bool))
+		if root == nil {
+			commandErr = fmt.Errorf("Nodegroup does not exist: %s", *treeRoot)
+			return
+		}
+		roots = []*enc.TreeNode{root}
+	}
+
+	switch *treeFormat {
+	case "ascii":
+		for _, root := range roots {
+			writeASCIITree(os.Stdout, root, "", "", make(map[string]bool))
+		}
+	case "dot":
+		writeDOTTree(os.Stdout, roots)
+	case "mermaid":
+		writeMermaidTree(os.Stdout, roots)
+	default:
+		handleErr(fmt.Errorf("Invalid format for command: [command: %s ; format: %s]", tree.FullCommand(), *treeFormat))
+	}
+}
+
+// findTreeNode searches the forest for a nodegroup by name or name@cluster
+func findTreeNode(treeNodes []*enc.TreeNode, name string, visited map[string]bool) *enc.TreeNode {
+	for _, treeNode := range treeNodes {
+		if visited[treeNode.ID()] {
+			continue
+		}
+		visited[treeNode.ID()] = true
+
+		if treeNode.ID() == name || treeNode.Name == name {
+			return treeNode
+		}
+
+		if found := findTreeNode(treeNode.Children, name, visited); found != nil {
+			return found
+		}
+	}
+
+	return nil
+}
+
+// treeLabel describes a nodegroup with its node count and any markers
+func treeLabel(treeNode *enc.TreeNode) string {
+	if treeNode.Missing {
+		return treeNode.ID() + " [missing]"
+	}
+
+	label := treeNode.ID()
+	if len(treeNode.Nodes) == 1 {
+		label += " (1 node)"
+	} else {
+		label += fmt.Sprintf(" (%d nodes)", len(treeNode.Nodes))
+	}
+
+	if treeNode.CrossENC {
+		label += " [cross-ENC]"
+	}
+
+	return label
+}
+
+func writeASCIITree(w io.Writer, treeNode *enc.TreeNode, prefix string, childPrefix string, path map[string]bool) {
+	if path[treeNode.ID()] {
+		fmt.Fprintf(w, "%s%s [cycle]\n", prefix, treeNode.ID())
+		return
+	}
+	path[treeNode.ID()] = true
+	defer delete(path, treeNode.ID())
+
+	fmt.Fprintf(w, "%s%s\n", prefix, treeLabel(treeNode))
+
+	entries := len(treeNode.Children)
+	if *treeNodes {
+		entries += len(treeNode.Nodes)
+	}
+
+	index := 0
+	for _, child := range treeNode.Children {
+		index++
+		if index == entries {
+			writeASCIITree(w, child, childPrefix+"└── ", childPrefix+"    ", path)
+		} else {
+			writeASCIITree(w, child, childPrefix+"├── ", childPrefix+"│   ", path)
+		}
+	}
+
+	if *treeNodes {
+		for _, node := range treeNode.Nodes {
+			index++
+			if index == entries {
+				fmt.Fprintf(w, "%s└── %s\n", childPrefix, node)
+			} else {
+				fmt.Fprintf(w, "%s├── %s\n", childPrefix, node)
+			}
+		}
+	}
+}
+
+// walkTree calls visit for every nodegroup and its parent, visiting each nodegroup once
+func walkTree(treeNodes []*enc.TreeNode, parent *enc.TreeNode, visited map[string]bool, visit func(treeNode *enc.TreeNode, parent *enc.TreeNode)) {
+	for _, treeNode := range treeNodes {
+		visit(treeNode, parent)
+		if visited[treeNode.ID()] {
+			continue
+		}
+		visited[treeNode.ID()] = true
+		walkTree(treeNode.Children, treeNode, visited, visit)
+	}
+}
+
+func writeDOTTree(w io.Writer, roots []*enc.TreeNode) {
+	fmt.Fprintln(w, "digraph nodegroups {")
+	fmt.Fprintln(w, "  rankdir=LR;")
+
+	declared := make(map[string]bool)
+	walkTree(roots, nil, make(map[string]bool), func(treeNode *enc.TreeNode, parent *enc.TreeNode) {
+		if !declared[treeNode.ID()] {
+			declared[treeNode.ID()] = true
+
+			style := ""
+			if treeNode.Missing {
+				style = ", style=dashed, color=red"
+			}
+			fmt.Fprintf(w, "  %q [label=%q%s];\n", treeNode.ID(), treeLabel(treeNode), style)
+
+			if *treeNodes {
+				for _, node := range treeNode.Nodes {
+					if !declared["node:"+node] {
+						declared["node:"+node] = true
+						fmt.Fprintf(w, "  %q [label=%q, shape=box];\n", "node:"+node, node)
+					}
+					fmt.Fprintf(w, "  %q -> %q [style=dotted];\n", treeNode.ID(), "node:"+node)
+				}
+			}
+		}
+
+		if parent != nil {
+			if treeNode.CrossENC {
+				fmt.Fprintf(w, "  %q -> %q [style=dashed, label=\"cross-ENC\"];\n", parent.ID(), treeNode.ID())
+			} else {
+				fmt.Fprintf(w, "  %q -> %q;\n", parent.ID(), treeNode.ID())
+			}
+		}
+	})
+
+	fmt.Fprintln(w, "}")
+}
+
+func writeMermaidTree(w io.Writer, roots []*enc.TreeNode) {
+	fmt.Fprintln(w, "graph TD")
+
+	// Mermaid IDs can't contain "@", so give every vertex a generated one
+	ids := make(map[string]string)
+	vertexID := func(key string, label string, shape string) string {
+		if id, ok := ids[key]; ok {
+			return id
+		}
+
+		id := fmt.Sprintf("v%d", len(ids))
+		ids[key] = id
+		label = strings.Replace(label, "\"", "#quot;", -1)
+		if shape == "box" {
+			fmt.Fprintf(w, "  %s[\"%s\"]\n", id, label)
+		} else {
+			fmt.Fprintf(w, "  %s([\"%s\"])\n", id, label)
+		}
+		return id
+	}
+
+	walkTree(roots, nil, make(map[string]bool), func(treeNode *enc.TreeNode, parent *enc.TreeNode) {
+		_, seen := ids[treeNode.ID()]
+		id := vertexID(treeNode.ID(), treeLabel(treeNode), "round")
+
+		if parent != nil {
+			if treeNode.CrossENC {
+				fmt.Fprintf(w, "  %s -.->|cross-ENC| %s\n", ids[parent.ID()], id)
+			} else {
+				fmt.Fprintf(w, "  %s --> %s\n", ids[parent.ID()], id)
+			}
+		}
+
+		if *treeNodes && !seen {
+			for _, node := range treeNode.Nodes {
+				fmt.Fprintf(w, "  %s -.- %s\n", id, vertexID("node:"+node, node, "box"))
+			}
+		}
+	})
+}
@@ -0,0 +1,29 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/thejokersthief/go-enc/enc"
+)
+
+func validateCommand(config *enc.Config) {
+	if config.ClassSchemas == nil {
+		handleErr(errors.New("Set --schema_dir (or GO_ENC_SCHEMA_DIR) to the directory of class schemas"))
+	}
+
+	violations := config.ValidateClassSchemas()
+
+	rows := make([][]string, 0, len(violations))
+	for _, violation := range violations {
+		rows = append(rows, []string{violation.ENC, violation.Nodegroup, violation.Node, violation.Class, violation.Path, violation.Message})
+	}
+
+	if commandErr = printOutput(*validateOutput, []string{"enc", "nodegroup", "node", "class", "path", "problem"}, rows, violations); commandErr != nil {
+		return
+	}
+
+	if len(violations) > 0 {
+		commandErr = fmt.Errorf("%d class parameters don't match their schema", len(violations))
+	}
+}
@@ -0,0 +1,170 @@
+package enc
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// AnsibleGroup is a group in an Ansible inventory
+type AnsibleGroup struct {
+	Hosts    []string               `json:"hosts,omitempty"`
+	Vars     map[string]interface{} `json:"vars,omitempty"`
+	Children []string               `json:"children,omitempty"`
+}
+
+// AnsibleInventory maps every nodegroup to an Ansible group and every node to a host whose
+// variables are its merged parameters
+type AnsibleInventory struct {
+	Groups   map[string]*AnsibleGroup
+	HostVars map[string]map[string]interface{}
+}
+
+var ansibleInvalidCharacters = regexp.MustCompile("[^A-Za-z0-9_]")
+
+// AnsibleGroupName flattens name@cluster into a valid Ansible group name, cluster__name
+func AnsibleGroupName(nodegroupName string, encName string) string {
+	nameSplit := strings.SplitN(QualifyNodegroup(nodegroupName, encName), "@", 2)
+	group := ansibleInvalidCharacters.ReplaceAllString(nameSplit[1]+"__"+nameSplit[0], "_")
+	if group[0] >= '0' && group[0] <= '9' {
+		group = "_" + group
+	}
+
+	return group
+}
+
+// AnsibleInventory builds an Ansible inventory from every ENC. Nodegroups become groups with
+// their own parameters as vars and Nodegroup.Parent becomes children, leaving Ansible to do the
+// inheritance. Host vars are the parameters from LookupNode, using conflictRule for nodes
+// present in several ENCs.
+func (c *Config) AnsibleInventory(conflictRule string) (*AnsibleInventory, error) {
+	inventory := &AnsibleInventory{
+		Groups:   make(map[string]*AnsibleGroup),
+		HostVars: make(map[string]map[string]interface{}),
+	}
+	groupSources := make(map[string]string)
+
+	for _, encName := range c.ENCNames() {
+		currentEnc := c.ENCs[encName]
+		for _, name := range currentEnc.NodegroupNames() {
+			nodegroup := currentEnc.Nodegroups[name]
+			qualified := QualifyNodegroup(name, encName)
+
+			group := AnsibleGroupName(name, encName)
+			if source, ok := groupSources[group]; ok && source != qualified {
+				return &AnsibleInventory{}, fmt.Errorf("Nodegroups %s and %s both map to the Ansible group %s", source, qualified, group)
+			}
+			groupSources[group] = qualified
+
+			ansibleGroup := inventory.group(group)
+			ansibleGroup.Hosts = append(ansibleGroup.Hosts, nodegroup.Nodes...)
+			if len(nodegroup.Parameters) > 0 {
+				ansibleGroup.Vars = nodegroup.Parameters
+			}
+
+			if nodegroup.Parent != "" {
+				parent := inventory.group(AnsibleGroupName(nodegroup.Parent, encName))
+				parent.Children = append(parent.Children, group)
+			}
+
+			for _, node := range nodegroup.Nodes {
+				if _, ok := inventory.HostVars[node]; ok {
+					continue
+				}
+
+				hostVars, err := c.AnsibleHostVars(node, conflictRule)
+				if err != nil {
+					return &AnsibleInventory{}, err
+				}
+				inventory.HostVars[node] = hostVars
+			}
+		}
+	}
+
+	for _, group := range inventory.Groups {
+		sort.Strings(group.Children)
+	}
+
+	return inventory, nil
+}
+
+// AnsibleHostVars returns the variables for a single host, i.e. the merged parameters of the node
+func (c *Config) AnsibleHostVars(host string, conflictRule string) (map[string]interface{}, error) {
+	lookup, err := c.LookupNode(host, conflictRule)
+	if err != nil {
+		return map[string]interface{}{}, err
+	}
+
+	if lookup.Nodegroup.Parameters == nil {
+		return map[string]interface{}{}, nil
+	}
+
+	return lookup.Nodegroup.Parameters, nil
+}
+
+func (inventory *AnsibleInventory) group(name string) *AnsibleGroup {
+	if _, ok := inventory.Groups[name]; !ok {
+		inventory.Groups[name] = &AnsibleGroup{}
+	}
+
+	return inventory.Groups[name]
+}
+
+// MarshalJSON produces the output Ansible expects from a dynamic inventory script called with --list
+func (inventory *AnsibleInventory) MarshalJSON() ([]byte, error) {
+	list := make(map[string]interface{}, len(inventory.Groups)+1)
+	for name, group := range inventory.Groups {
+		list[name] = group
+	}
+	list["_meta"] = map[string]interface{}{"hostvars": inventory.HostVars}
+
+	return json.Marshal(list)
+}
+
+// MarshalYAML produces a static Ansible YAML inventory
+func (inventory *AnsibleInventory) MarshalYAML() (interface{}, error) {
+	hosts := make(map[string]interface{}, len(inventory.HostVars))
+	for host, vars := range inventory.HostVars {
+		if len(vars) > 0 {
+			hosts[host] = vars
+		} else {
+			hosts[host] = nil
+		}
+	}
+
+	children := make(map[string]interface{}, len(inventory.Groups))
+	for name, group := range inventory.Groups {
+		yamlGroup := make(map[string]interface{})
+
+		if len(group.Hosts) > 0 {
+			groupHosts := make(map[string]interface{}, len(group.Hosts))
+			for _, host := range group.Hosts {
+				groupHosts[host] = nil
+			}
+			yamlGroup["hosts"] = groupHosts
+		}
+
+		if len(group.Vars) > 0 {
+			yamlGroup["vars"] = group.Vars
+		}
+
+		if len(group.Children) > 0 {
+			groupChildren := make(map[string]interface{}, len(group.Children))
+			for _, child := range group.Children {
+				groupChildren[child] = nil
+			}
+			yamlGroup["children"] = groupChildren
+		}
+
+		children[name] = yamlGroup
+	}
+
+	return map[string]interface{}{
+		"all": map[string]interface{}{
+			"hosts":    hosts,
+			"children": children,
+		},
+	}, nil
+}
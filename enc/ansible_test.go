@@ -0,0 +1,70 @@
+package enc
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+)
+
+func TestAnsibleGroupName(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("production__website", AnsibleGroupName("website", "production"))
+	assert.Equal("production__website", AnsibleGroupName("website@production", "staging"))
+	assert.Equal("eu_west_1__web_canary", AnsibleGroupName("web-canary", "eu-west.1"))
+	assert.Equal("_2019__web", AnsibleGroupName("web", "2019"))
+}
+
+func TestAnsibleInventory(t *testing.T) {
+	assert := assert.New(t)
+	c := newFixtureConfig()
+
+	inventory, err := c.AnsibleInventory(NodeConflictError)
+	assert.Nil(err)
+
+	assert.Len(inventory.Groups, 5)
+	assert.Equal(&AnsibleGroup{
+		Hosts:    []string{"web-0001", "web-0002"},
+		Children: []string{"production__website_canary", "staging__staging_web"},
+	}, inventory.Groups["production__website"])
+	assert.Equal(map[string]interface{}{"datacenter": "dub1"}, inventory.Groups["production__globals"].Vars)
+	assert.Equal([]string{"production__database", "production__website"}, inventory.Groups["production__globals"].Children)
+
+	assert.Equal(map[string]interface{}{"datacenter": "dub2"}, inventory.HostVars["web-0101"])
+	assert.Equal(map[string]interface{}{"datacenter": "dub1"}, inventory.HostVars["db-0001"])
+
+	list, err := json.Marshal(inventory)
+	assert.Nil(err)
+
+	var decoded map[string]interface{}
+	assert.Nil(json.Unmarshal(list, &decoded))
+	assert.Contains(decoded, "_meta")
+	assert.Contains(decoded, "staging__staging_web")
+
+	static, err := yaml.Marshal(inventory)
+	assert.Nil(err)
+	assert.Contains(string(static), "production__website_canary:\n")
+}
+
+func TestAnsibleInventoryCollision(t *testing.T) {
+	assert := assert.New(t)
+	c := newFixtureConfig()
+	c.ENCs["production"].AddNodegroup("website-canary", "", map[string]interface{}{}, []string{}, map[string]interface{}{})
+
+	_, err := c.AnsibleInventory(NodeConflictError)
+	assert.NotNil(err)
+}
+
+func TestAnsibleHostVars(t *testing.T) {
+	assert := assert.New(t)
+	c := newFixtureConfig()
+
+	hostVars, err := c.AnsibleHostVars("web-0001", NodeConflictError)
+	assert.Nil(err)
+	assert.Equal(map[string]interface{}{"datacenter": "dub1"}, hostVars)
+
+	_, err = c.AnsibleHostVars("web-9999", NodeConflictError)
+	assert.NotNil(err)
+}
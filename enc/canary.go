@@ -0,0 +1,253 @@
+package enc
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Canary is what creating, promoting or aborting a canary nodegroup changed
+type Canary struct {
+	Nodegroup string                 `json:"nodegroup" yaml:"nodegroup"`
+	Parent    string                 `json:"parent" yaml:"parent"`
+	Nodes     []string               `json:"nodes" yaml:"nodes"`
+	Changes   []ClassificationChange `json:"changes" yaml:"changes"`
+}
+
+// CanaryNodes picks count nodes, or percent of them rounded up, by the hash of each certname with
+// the canary's name. The same nodes are picked every time, and picking more keeps those.
+func CanaryNodes(nodes []string, canaryName string, count int, percent float64) []string {
+	if percent > 0 {
+		count = int(math.Ceil(float64(len(nodes)) * percent / 100))
+	}
+	if count > len(nodes) {
+		count = len(nodes)
+	}
+	if count <= 0 {
+		return []string{}
+	}
+
+	hashes := make(map[string]uint64, len(nodes))
+	for _, node := range nodes {
+		hash := fnv.New64a()
+		hash.Write([]byte(canaryName + "/" + node))
+		hashes[node] = hash.Sum64()
+	}
+
+	ordered := append([]string{}, nodes...)
+	sort.Sort(byCanaryHash{ordered, hashes})
+
+	picked := ordered[:count]
+	sort.Strings(picked)
+	return picked
+}
+
+type byCanaryHash struct {
+	nodes  []string
+	hashes map[string]uint64
+}
+
+func (s byCanaryHash) Len() int      { return len(s.nodes) }
+func (s byCanaryHash) Swap(i, j int) { s.nodes[i], s.nodes[j] = s.nodes[j], s.nodes[i] }
+func (s byCanaryHash) Less(i, j int) bool {
+	a, b := s.hashes[s.nodes[i]], s.hashes[s.nodes[j]]
+	if a != b {
+		return a < b
+	}
+	return s.nodes[i] < s.nodes[j]
+}
+
+// CreateCanary moves count nodes, or percent of them, of a nodegroup into a child nodegroup named
+// canaryName (<nodegroup>_canary by default) with parameters, set by path, and environment
+// overriding the nodegroup's. Run again on an existing canary, it moves more nodes into it until it
+// has that many of the two nodegroups' nodes.
+func (enc *ENC) CreateCanary(nodegroupName string, canaryName string, count int, percent float64, parameters map[string]interface{}, env string) (*Canary, error) {
+	if count <= 0 && percent <= 0 {
+		return &Canary{}, errors.New("Give a count or percentage of nodes for the canary")
+	}
+	if percent > 100 {
+		return &Canary{}, fmt.Errorf("Canary percentage should be at most 100: %v", percent)
+	}
+
+	nodegroup, err := enc.GetNodegroup(nodegroupName)
+	if err != nil {
+		return &Canary{}, err
+	}
+
+	if canaryName == "" {
+		canaryName = nodegroupName + "_canary"
+	}
+	parent := QualifyNodegroup(nodegroupName, enc.Name)
+
+	before := enc.snapshotLinkedClassifications()
+
+	canary, err := enc.GetNodegroup(canaryName)
+	if err != nil {
+		if _, err := enc.AddNodegroup(canaryName, parent, map[string]interface{}{}, []string{}, map[string]interface{}{}); err != nil {
+			return &Canary{}, err
+		}
+		canary, _ = enc.GetNodegroup(canaryName)
+	} else if QualifyNodegroup(canary.Parent, enc.Name) != parent {
+		return &Canary{}, fmt.Errorf("Nodegroup %s already exists and isn't a child of %s", canaryName, nodegroupName)
+	}
+
+	for _, path := range sortedKeys(parameters) {
+		if _, err := enc.SetParameterPath(canaryName, path, parameters[path]); err != nil {
+			return &Canary{}, err
+		}
+	}
+	if env != "" {
+		if _, err := enc.SetEnvironment(canaryName, env); err != nil {
+			return &Canary{}, err
+		}
+	}
+
+	candidates := append([]string{}, nodegroup.Nodes...)
+	for _, node := range canary.Nodes {
+		candidates = appendUnique(candidates, node)
+	}
+	moving := make([]string, 0)
+	for _, node := range CanaryNodes(candidates, canaryName, count, percent) {
+		if !containsString(canary.Nodes, node) {
+			moving = append(moving, node)
+		}
+	}
+	if err := enc.moveNodesWithin(nodegroupName, canaryName, moving); err != nil {
+		return &Canary{}, err
+	}
+
+	canary, _ = enc.GetNodegroup(canaryName)
+	return &Canary{
+		Nodegroup: canaryName,
+		Parent:    parent,
+		Nodes:     canary.Nodes,
+		Changes:   diffClassifications(before, enc.snapshotLinkedClassifications()),
+	}, nil
+}
+
+// PromoteCanary merges the classes, parameters and environment of a canary into its parent, so
+// every node of the parent gets them, then moves the canary's nodes back and removes it
+func (enc *ENC) PromoteCanary(canaryName string) (*Canary, error) {
+	return enc.endCanary(canaryName, true)
+}
+
+// AbortCanary moves the nodes of a canary back to its parent and removes it, dropping its overrides
+func (enc *ENC) AbortCanary(canaryName string) (*Canary, error) {
+	return enc.endCanary(canaryName, false)
+}
+
+func (enc *ENC) endCanary(canaryName string, promote bool) (*Canary, error) {
+	canary, err := enc.GetNodegroup(canaryName)
+	if err != nil {
+		return &Canary{}, err
+	}
+
+	if canary.Parent == "" {
+		return &Canary{}, fmt.Errorf("Nodegroup %s has no parent to end the canary into", canaryName)
+	}
+	parentName := strings.TrimSuffix(canary.Parent, "@"+enc.Name)
+	if strings.Contains(parentName, "@") {
+		return &Canary{}, fmt.Errorf("Parent of %s is in another ENC: %s", canaryName, canary.Parent)
+	}
+	parent, err := enc.GetNodegroup(parentName)
+	if err != nil {
+		return &Canary{}, err
+	}
+
+	if children := enc.ConfigLink.childNodegroups(QualifyNodegroup(canaryName, enc.Name)); len(children) > 0 {
+		return &Canary{}, fmt.Errorf("Nodegroup %s has children: %s", canaryName, strings.Join(children, ", "))
+	}
+
+	before := enc.snapshotLinkedClassifications()
+
+	if promote {
+		// Check every class against its schema before changing anything
+		classes := make(map[string]interface{}, len(canary.Classes))
+		for class, body := range canary.Classes {
+			parentBody, _ := parent.Classes[class].(map[string]interface{})
+			canaryBody, _ := body.(map[string]interface{})
+			merged := MergeNestedMaps(copyValue(parentBody).(map[string]interface{}), canaryBody)
+			if err := enc.checkClassSchema(class, merged); err != nil {
+				return &Canary{}, err
+			}
+			classes[class] = merged
+		}
+
+		if len(classes) > 0 && parent.Classes == nil {
+			parent.Classes = make(map[string]interface{})
+		}
+		for class, body := range classes {
+			parent.Classes[class] = body
+		}
+		if len(canary.Parameters) > 0 {
+			parent.Parameters = MergeNestedMaps(parent.Parameters, canary.Parameters)
+		}
+		enc.Nodegroups[parentName] = *parent
+
+		if canary.Environment != "" {
+			if _, err := enc.SetEnvironment(parentName, canary.Environment); err != nil {
+				return &Canary{}, err
+			}
+		}
+	}
+
+	nodes := append([]string{}, canary.Nodes...)
+	if err := enc.moveNodesWithin(canaryName, parentName, nodes); err != nil {
+		return &Canary{}, err
+	}
+	enc.RemoveNodegroup(canaryName)
+
+	return &Canary{
+		Nodegroup: canaryName,
+		Parent:    QualifyNodegroup(parentName, enc.Name),
+		Nodes:     nodes,
+		Changes:   diffClassifications(before, enc.snapshotLinkedClassifications()),
+	}, nil
+}
+
+// moveNodesWithin moves nodes between two nodegroups of the ENC, keeping any already in the second
+// there, then rebuilds the node chains like MoveNode
+func (enc *ENC) moveNodesWithin(from string, to string, nodes []string) error {
+	fromName, toName := strings.TrimSuffix(from, "@"+enc.Name), strings.TrimSuffix(to, "@"+enc.Name)
+	fromNodegroup, ok := enc.Nodegroups[fromName]
+	if !ok {
+		return fmt.Errorf("Nodegroup does not exist: %s", from)
+	}
+	toNodegroup, ok := enc.Nodegroups[toName]
+	if !ok {
+		return fmt.Errorf("Nodegroup does not exist: %s", to)
+	}
+
+	for _, node := range nodes {
+		fromNodegroup.Nodes = removeByValueSS(fromNodegroup.Nodes, node)
+		if !containsString(toNodegroup.Nodes, node) {
+			toNodegroup.Nodes = append(toNodegroup.Nodes, node)
+		}
+	}
+	enc.Nodegroups[fromName] = fromNodegroup
+	enc.Nodegroups[toName] = toNodegroup
+
+	enc.rebuildNodeChains()
+	return nil
+}
+
+// childNodegroups returns the nodegroups of every ENC whose parent is the qualified nodegroup
+func (c *Config) childNodegroups(qualified string) []string {
+	children := make([]string, 0)
+	if c == nil {
+		return children
+	}
+
+	for _, encName := range c.ENCNames() {
+		for _, name := range c.ENCs[encName].NodegroupNames() {
+			if QualifyNodegroup(c.ENCs[encName].Nodegroups[name].Parent, encName) == qualified {
+				children = append(children, QualifyNodegroup(name, encName))
+			}
+		}
+	}
+
+	return children
+}
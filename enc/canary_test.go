@@ -0,0 +1,132 @@
+package enc
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanaryNodes(t *testing.T) {
+	assert := assert.New(t)
+
+	nodes := []string{}
+	for i := 1; i <= 10; i++ {
+		nodes = append(nodes, fmt.Sprintf("web-%04d", i))
+	}
+
+	two := CanaryNodes(nodes, "website_canary", 2, 0)
+	assert.Len(two, 2)
+	assert.Equal(two, CanaryNodes(nodes, "website_canary", 2, 0))
+
+	// Picking more keeps the nodes already picked, whatever order the nodes come in
+	reversed := append([]string{}, nodes...)
+	reverse(reversed)
+	three := CanaryNodes(reversed, "website_canary", 0, 25)
+	assert.Len(three, 3)
+	assert.Subset(three, two)
+
+	assert.Equal(nodes, CanaryNodes(nodes, "website_canary", 20, 0))
+	assert.Empty(CanaryNodes([]string{}, "website_canary", 0, 50))
+}
+
+func newCanaryFixture() (*Config, *ENC) {
+	c := newFixtureConfig()
+	production := c.ENCs["production"]
+	production.AddNodes("database", []string{"db-0002", "db-0003", "db-0004"})
+	return c, production
+}
+
+func TestCreateCanary(t *testing.T) {
+	assert := assert.New(t)
+	_, production := newCanaryFixture()
+
+	_, err := production.CreateCanary("database", "", 0, 0, nil, "")
+	assert.EqualError(err, "Give a count or percentage of nodes for the canary")
+	_, err = production.CreateCanary("database", "", 0, 150, nil, "")
+	assert.EqualError(err, "Canary percentage should be at most 100: 150")
+	_, err = production.CreateCanary("database", "website", 1, 0, nil, "")
+	assert.EqualError(err, "Nodegroup website already exists and isn't a child of database")
+
+	canary, err := production.CreateCanary("database", "", 0, 50, map[string]interface{}{"mysql.version": "8.0"}, "canary")
+	assert.Nil(err)
+	assert.Equal("database_canary", canary.Nodegroup)
+	assert.Equal("database@production", canary.Parent)
+	assert.Equal(CanaryNodes([]string{"db-0001", "db-0002", "db-0003", "db-0004"}, "database_canary", 2, 0), canary.Nodes)
+	assert.Len(canary.Changes, 4)
+
+	for _, node := range canary.Nodes {
+		classified, err := production.GetNode(node)
+		assert.Nil(err)
+		assert.Equal("canary", classified.Environment)
+		assert.Equal(map[string]interface{}{"version": "8.0"}, classified.Parameters["mysql"])
+		assert.NotContains(production.Nodegroups["database"].Nodes, node)
+	}
+
+	// Running it again only moves more nodes in
+	more, err := production.CreateCanary("database", "", 3, 0, nil, "")
+	assert.Nil(err)
+	assert.Len(more.Nodes, 3)
+	assert.Subset(more.Nodes, canary.Nodes)
+	assert.Len(more.Changes, 2)
+
+	// database has a child now, and the nodes leave no chain to it behind
+	blue, err := production.CreateCanary("database", "database_blue", 1, 0, nil, "")
+	assert.Nil(err)
+	for _, node := range blue.Nodes {
+		_, ok := production.Nodes.Find(node + "$$globals@production$$database@production")
+		assert.False(ok)
+		_, ok = production.Nodes.Find(node + "$$globals@production$$database@production$$database_blue@production")
+		assert.True(ok)
+	}
+}
+
+func TestPromoteCanary(t *testing.T) {
+	assert := assert.New(t)
+	_, production := newCanaryFixture()
+
+	canary, _ := production.CreateCanary("database", "", 1, 0, map[string]interface{}{"mysql.version": "8.0"}, "canary")
+	production.AddClass("database_canary", "mysql")
+	production.SetClassParameter("database_canary", "mysql", "innodb_buffer_pool_size", "2G")
+
+	promoted, err := production.PromoteCanary("database_canary")
+	assert.Nil(err)
+	assert.Equal(canary.Nodes, promoted.Nodes)
+	// Every node but the canary's gets the overrides
+	assert.Len(promoted.Changes, 9)
+
+	database := production.Nodegroups["database"]
+	assert.Equal(map[string]interface{}{"version": "8.0"}, database.Parameters["mysql"])
+	assert.Equal(map[string]interface{}{"innodb_buffer_pool_size": "2G"}, database.Classes["mysql"])
+	assert.Equal("canary", database.Environment)
+	assert.Len(database.Nodes, 4)
+	assert.NotContains(production.Nodegroups, "database_canary")
+
+	// Nodes of other ENCs inheriting from the parent get the overrides too
+	c := newFixtureConfig()
+	promoted, err = c.ENCs["production"].PromoteCanary("website_canary")
+	assert.Nil(err)
+	assert.Contains(promoted.Changes, ClassificationChange{Node: "web-0101", Field: "class.nginx.worker_processes", Change: "changed", Before: "8", After: "2"})
+}
+
+func TestAbortCanary(t *testing.T) {
+	assert := assert.New(t)
+	c, production := newCanaryFixture()
+	before := production.snapshotClassifications()
+
+	canary, _ := production.CreateCanary("database", "", 2, 0, map[string]interface{}{"mysql.version": "8.0"}, "canary")
+	aborted, err := production.AbortCanary("database_canary")
+	assert.Nil(err)
+	assert.Equal(canary.Nodes, aborted.Nodes)
+	assert.Len(aborted.Changes, 4)
+	assert.Empty(diffClassifications(before, production.snapshotClassifications()))
+	assert.NotContains(production.Nodegroups, "database_canary")
+	assert.Nil(production.Nodegroups["database"].Parameters["mysql"])
+
+	_, err = production.AbortCanary("website")
+	assert.EqualError(err, "Nodegroup website has children: website_canary@production, staging_web@staging")
+	_, err = c.ENCs["staging"].AbortCanary("staging_web")
+	assert.EqualError(err, "Parent of staging_web is in another ENC: website@production")
+	_, err = production.AbortCanary("globals")
+	assert.EqualError(err, "Nodegroup globals has no parent to end the canary into")
+}
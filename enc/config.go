@@ -1,20 +1,37 @@
 package enc
 
 import (
-	"encoding/json"
 	"errors"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
-	"strings"
-
-	"gopkg.in/yaml.v2"
+	"sort"
 )
 
 // Config stores the configuration for our ENC
 type Config struct {
 	ENCs        map[string]*ENC
 	GlobPattern string
+	// Precedence orders ENC names for lookups that search every ENC, highest first
+	Precedence []string
+	// SecretKeys decrypt secret parameters when nodes are classified, which are left encrypted if nil
+	SecretKeys SecretKeys
+	// ClassSchemas validate the parameters of the classes they're named after
+	ClassSchemas map[string]Schema
+	// PuppetCatalog is the classes defined in the Puppet modules, which changes to classes are
+	// checked against if it isn't nil
+	PuppetCatalog PuppetCatalog
+	// Environments are the environments nodegroups can be in, any if nil
+	Environments []string
+	// Warnings are problems found while changing the ENCs that don't stop the change
+	Warnings []string
+	// yamlDocuments keeps YAML files as they were read, by file name, to preserve their formatting
+	yamlDocuments map[string]*yamlDocument
+}
+
+func (c *Config) warn(format string, args ...interface{}) {
+	c.Warnings = append(c.Warnings, fmt.Sprintf(format, args...))
 }
 
 // NewConfig generates a new ENC from the config. One ENC for each file matched by the glob pattern
@@ -33,26 +50,15 @@ func NewConfig(globPatttern string) *Config {
 
 	encNodeTracker := make(map[string]map[string][]string, 0)
 	for _, file := range matchingFiles {
-		var (
-			enc            *ENC
-			nodegroupNodes map[string][]string
-		)
+		format, formatErr := FormatForFile(file)
+		errCheck(formatErr)
 
-		extension := strings.ToLower(filepath.Ext(file))
-		switch extension {
-		case ".json":
-			enc = NewENC("json", file)
-			nodegroupNodes = c.processJSONFile(file, enc)
-		case ".yaml", ".yml":
-			enc = NewENC("yaml", file)
-			nodegroupNodes = c.processYAMLFile(file, enc)
-		default:
-			panic(errors.New("Unrecognised file extension, expecting: json|yaml"))
-		}
+		enc := NewENC(format, file)
+		nodegroupNodes := c.processFile(file, enc)
 
 		filename := filepath.Base(file)
 		// Strip extension from filename
-		filename = filename[0 : len(filename)-len(extension)]
+		filename = filename[0 : len(filename)-len(filepath.Ext(file))]
 		encNodeTracker[filename] = nodegroupNodes
 		enc.Name = filename
 		enc.ConfigLink = c
@@ -70,51 +76,83 @@ func NewConfig(globPatttern string) *Config {
 	return c
 }
 
+// ENCNames returns the sorted names of every ENC loaded by the glob pattern
+func (c *Config) ENCNames() []string {
+	names := make([]string, 0, len(c.ENCs))
+	for name := range c.ENCs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// GetENC retrieves a loaded ENC by name
+func (c *Config) GetENC(name string) (*ENC, error) {
+	if val, ok := c.ENCs[name]; ok {
+		return val, nil
+	}
+
+	return &ENC{}, fmt.Errorf("ENC does not exist: %s", name)
+}
+
+// ClassNames returns the sorted names of every class applied in any ENC
+func (c *Config) ClassNames() []string {
+	seen := make(map[string]bool)
+	names := make([]string, 0)
+	for _, currentEnc := range c.ENCs {
+		for _, class := range currentEnc.ClassNames() {
+			if !seen[class] {
+				seen[class] = true
+				names = append(names, class)
+			}
+		}
+	}
+	sort.Strings(names)
+
+	return names
+}
+
 func (c *Config) WriteOutENC() {
 	for _, current_enc := range c.ENCs {
-		file, fileErr := os.Create(current_enc.FileName)
-		defer file.Close()
-		errCheck(fileErr)
-
-		var encContents []byte
-		var marshalErr error
+		format, formatErr := FormatForFile(current_enc.FileName)
+		errCheck(formatErr)
 
-		switch extension := strings.ToLower(filepath.Ext(current_enc.FileName)); extension {
-		case ".json":
-			encContents, marshalErr = json.Marshal(current_enc.Nodegroups)
-		case ".yaml", ".yml":
-			encContents, marshalErr = yaml.Marshal(current_enc.Nodegroups)
+		var (
+			encContents []byte
+			marshalErr  error
+		)
+		if doc, ok := c.yamlDocuments[current_enc.FileName]; ok && format == "yaml" {
+			encContents, marshalErr = doc.Encode(current_enc.Nodegroups)
+		} else {
+			encContents, marshalErr = EncodeENC(format, current_enc.Nodegroups)
 		}
 		errCheck(marshalErr)
 
+		// Only truncate the file once the new contents are ready
+		file, fileErr := os.Create(current_enc.FileName)
+		defer file.Close()
+		errCheck(fileErr)
+
 		file.Write(encContents)
 		file.Sync()
 	}
 }
 
-func (c *Config) processJSONFile(filepath string, enc *ENC) map[string][]string {
-	data, fileErr := ioutil.ReadFile(filepath)
-	errCheck(fileErr)
-
-	var rawEnc map[string]interface{}
-	jsonParseErr := json.Unmarshal(data, &rawEnc)
-	errCheck(jsonParseErr)
-
-	return c.processRawENC(rawEnc, enc)
-}
-
-func (c *Config) processYAMLFile(filepath string, enc *ENC) map[string][]string {
+func (c *Config) processFile(filepath string, enc *ENC) map[string][]string {
 	data, fileErr := ioutil.ReadFile(filepath)
 	errCheck(fileErr)
 
-	var rawEnc map[string]interface{}
-	yamlParseErr := yaml.Unmarshal(data, &rawEnc)
-	errCheck(yamlParseErr)
+	rawEnc, parseErr := DecodeENC(enc.ConfigType, data)
+	errCheck(parseErr)
 
-	// YAML unmarshalling returns type map[interface{}]interface{} regardless of provided type
-	// so until that's fixed, some conversion has to take place
-	for k, v := range rawEnc {
-		rawEnc[k] = stringifyYAMLMapKeys(v)
+	if enc.ConfigType == "yaml" {
+		if doc := parseYAMLDocument(data); doc != nil {
+			if c.yamlDocuments == nil {
+				c.yamlDocuments = make(map[string]*yamlDocument)
+			}
+			c.yamlDocuments[filepath] = doc
+		}
 	}
 
 	return c.processRawENC(rawEnc, enc)
@@ -128,10 +166,11 @@ func (c *Config) processRawENC(rawEnc map[string]interface{}, enc *ENC) map[stri
 		attrs := attributes.(map[string]interface{})
 
 		var (
-			parent     string
-			classes    map[string]interface{}
-			parameters map[string]interface{}
-			ok         bool
+			parent      string
+			classes     map[string]interface{}
+			parameters  map[string]interface{}
+			environment string
+			ok          bool
 		)
 
 		if parent, ok = attrs["parent"].(string); !ok {
@@ -146,6 +185,10 @@ func (c *Config) processRawENC(rawEnc map[string]interface{}, enc *ENC) map[stri
 			parameters = make(map[string]interface{}, 0)
 		}
 
+		if environment, ok = attrs["environment"].(string); !ok {
+			environment = ""
+		}
+
 		enc.AddNodegroup(
 			nodegroup,
 			parent,
@@ -153,6 +196,18 @@ func (c *Config) processRawENC(rawEnc map[string]interface{}, enc *ENC) map[stri
 			make([]string, 0),
 			parameters)
 
+		loaded := enc.Nodegroups[nodegroup]
+		loaded.Environment = environment
+		for key, value := range attrs {
+			if !knownNodegroupField(key) {
+				if loaded.Extra == nil {
+					loaded.Extra = make(map[string]interface{})
+				}
+				loaded.Extra[key] = value
+			}
+		}
+		enc.Nodegroups[nodegroup] = loaded
+
 		nodegroupNodes[nodegroup] = make([]string, 0)
 		if attrs["nodes"] != nil {
 			for _, node := range attrs["nodes"].([]interface{}) {
@@ -272,3 +272,18 @@ func testNewYAMLConfig(t *testing.T) {
   gotENC.ConfigLink = nil
   assert.Equal(wantEnc, *gotENC)
 }
+
+func TestConfigAccessors(t *testing.T) {
+  assert := assert.New(t)
+  c := newFixtureConfig()
+
+  assert.Equal([]string{"production", "staging"}, c.ENCNames())
+  assert.Equal([]string{"mysql", "nginx", "ntp"}, c.ClassNames())
+
+  gotENC, err := c.GetENC("staging")
+  assert.Nil(err)
+  assert.Equal(c.ENCs["staging"], gotENC)
+
+  _, err = c.GetENC("missing")
+  assert.NotNil(err)
+}
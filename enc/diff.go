@@ -0,0 +1,173 @@
+package enc
+
+import (
+	"sort"
+	"strings"
+)
+
+// ClassificationChange is a difference in how a node is classified, before and after a change to
+// the ENC. Field is environment, class.<name>, class.<name>.<parameter> or param.<parameter>, with
+// nested parameters dotted, and Change is added, removed or changed. Values holding a secret are
+// never decrypted, and show as EncryptedValue.
+type ClassificationChange struct {
+	Node   string `json:"node" yaml:"node"`
+	Field  string `json:"field" yaml:"field"`
+	Change string `json:"change" yaml:"change"`
+	Before string `json:"before,omitempty" yaml:"before,omitempty"`
+	After  string `json:"after,omitempty" yaml:"after,omitempty"`
+}
+
+// EncryptedValue stands in for the before or after value of a ClassificationChange with a secret
+const EncryptedValue = "<encrypted>"
+
+// classificationSnapshot is the flattened classification of some nodes, by node
+type classificationSnapshot map[string]map[string]string
+
+// snapshotClassifications flattens the classification of every node of the ENC, to compare with
+// after a change
+func (enc *ENC) snapshotClassifications() classificationSnapshot {
+	snapshot := make(classificationSnapshot)
+	for _, nodeName := range enc.NodeNames() {
+		for node, fields := range enc.classificationOf(nodeName) {
+			snapshot[node] = fields
+		}
+	}
+
+	return snapshot
+}
+
+// snapshotENCClassifications flattens the classification of every node of several ENCs. A node in
+// more than one of them is classified by the first.
+func snapshotENCClassifications(encs []*ENC) classificationSnapshot {
+	snapshot := make(classificationSnapshot)
+	for _, currentEnc := range encs {
+		for node, fields := range currentEnc.snapshotClassifications() {
+			if _, ok := snapshot[node]; !ok {
+				snapshot[node] = fields
+			}
+		}
+	}
+
+	return snapshot
+}
+
+// snapshotLinkedClassifications flattens the classification of every node of the ENC and of the
+// other ENCs of its config, whose nodegroups can inherit from this one's
+func (enc *ENC) snapshotLinkedClassifications() classificationSnapshot {
+	encs := []*ENC{enc}
+	if enc.ConfigLink != nil {
+		for _, encName := range enc.ConfigLink.ENCNames() {
+			if encName != enc.Name {
+				encs = append(encs, enc.ConfigLink.ENCs[encName])
+			}
+		}
+	}
+
+	return snapshotENCClassifications(encs)
+}
+
+// classificationIn flattens the classification of a single node by the first of several ENCs that
+// has it, like snapshotENCClassifications
+func classificationIn(encs []*ENC, nodeName string) classificationSnapshot {
+	for _, currentEnc := range encs {
+		if _, ok := currentEnc.Nodes.Find(nodeName); ok {
+			return currentEnc.classificationOf(nodeName)
+		}
+	}
+
+	return classificationSnapshot{}
+}
+
+// classificationOf flattens the classification of a single node, with its references resolved
+// but its secrets left encrypted, so they're compared as they're written. A node that can't be
+// classified gets a single error field.
+func (enc *ENC) classificationOf(nodeName string) classificationSnapshot {
+	node, err := enc.mergedNode(nodeName)
+	if err == nil {
+		node, err = interpolateNodegroup(node, nodeName, strings.Join(enc.NodeNodegroups(nodeName), ","))
+	}
+	if err == nil {
+		var fields map[string]string
+		if fields, err = classificationFields(node); err == nil {
+			return classificationSnapshot{nodeName: fields}
+		}
+	}
+
+	return classificationSnapshot{nodeName: map[string]string{"error": err.Error()}}
+}
+
+func classificationFields(node *Nodegroup) (map[string]string, error) {
+	fields := make(map[string]string)
+	if node.Environment != "" {
+		fields["environment"] = node.Environment
+	}
+
+	for class, body := range node.Classes {
+		fields["class."+class] = ""
+		if parameters, ok := body.(map[string]interface{}); ok {
+			if err := flattenParameters(fields, "class."+class+".", parameters); err != nil {
+				return fields, err
+			}
+		}
+	}
+
+	return fields, flattenParameters(fields, "param.", node.Parameters)
+}
+
+// diffClassifications lists what changed between two snapshots, by node then field
+func diffClassifications(before classificationSnapshot, after classificationSnapshot) []ClassificationChange {
+	nodes := make([]string, 0, len(before)+len(after))
+	for node := range before {
+		nodes = append(nodes, node)
+	}
+	for node := range after {
+		if _, ok := before[node]; !ok {
+			nodes = append(nodes, node)
+		}
+	}
+	sort.Strings(nodes)
+
+	changes := make([]ClassificationChange, 0)
+	for _, node := range nodes {
+		beforeFields, afterFields := before[node], after[node]
+
+		fields := make([]string, 0, len(beforeFields)+len(afterFields))
+		for field := range beforeFields {
+			fields = append(fields, field)
+		}
+		for field := range afterFields {
+			if _, ok := beforeFields[field]; !ok {
+				fields = append(fields, field)
+			}
+		}
+		sort.Strings(fields)
+
+		for _, field := range fields {
+			beforeValue, inBefore := beforeFields[field]
+			afterValue, inAfter := afterFields[field]
+			change := ClassificationChange{Node: node, Field: field, Before: hideSecrets(beforeValue), After: hideSecrets(afterValue)}
+			switch {
+			case !inBefore:
+				change.Change = "added"
+			case !inAfter:
+				change.Change = "removed"
+			case beforeValue != afterValue:
+				change.Change = "changed"
+			default:
+				continue
+			}
+			changes = append(changes, change)
+		}
+	}
+
+	return changes
+}
+
+// hideSecrets replaces a flattened value that has a secret in it with EncryptedValue
+func hideSecrets(value string) string {
+	if secretInText.MatchString(value) {
+		return EncryptedValue
+	}
+
+	return value
+}
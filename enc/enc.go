@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"sort"
 	"strings"
 
 	"github.com/derekparker/trie"
@@ -15,11 +16,13 @@ var (
 
 // Nodegroup represents groups of nodes and meta information about them
 type Nodegroup struct {
-	Parent      string                 `json:"parent,omitempty" yaml:"parent,omitempty"`
-	Classes     map[string]interface{} `json:"classes,omitempty" yaml:"classes,omitempty"`
-	Nodes       []string               `json:"nodes,omitempty" yaml:"nodes,omitempty"`
-	Parameters  map[string]interface{} `json:"parameters,omitempty" yaml:"parameters,omitempty"`
-	Environment string                 `json:"environment,omitempty" yaml:"environment,omitempty"`
+	Parent      string                 `json:"parent,omitempty" yaml:"parent,omitempty" toml:"parent,omitempty"`
+	Classes     map[string]interface{} `json:"classes,omitempty" yaml:"classes,omitempty" toml:"classes,omitempty"`
+	Nodes       []string               `json:"nodes,omitempty" yaml:"nodes,omitempty" toml:"nodes,omitempty"`
+	Parameters  map[string]interface{} `json:"parameters,omitempty" yaml:"parameters,omitempty" toml:"parameters,omitempty"`
+	Environment string                 `json:"environment,omitempty" yaml:"environment,omitempty" toml:"environment,omitempty"`
+	// Extra holds keys of the nodegroup go-enc doesn't know about, so they survive being written back
+	Extra map[string]interface{} `json:"-" yaml:",inline" toml:"-"`
 }
 
 // ENC represents the entire structure of the External Node Classifier
@@ -97,12 +100,11 @@ func (enc *ENC) GetNodegroup(nodegroupName string) (*Nodegroup, error) {
 			nodegroup, cluster = nodegroupName, enc.Name
 		}
 
-		clusterNodegroups = config.ENCs[cluster].Nodegroups
-
-		keys := make([]string, 0)
-		for key, _ := range clusterNodegroups {
-			keys = append(keys, key)
+		clusterEnc, ok := config.ENCs[cluster]
+		if !ok {
+			return &Nodegroup{}, fmt.Errorf("ENC does not exist: %s", cluster)
 		}
+		clusterNodegroups = clusterEnc.Nodegroups
 	} else {
 		clusterNodegroups, nodegroup = enc.Nodegroups, nodegroupName
 	}
@@ -114,22 +116,114 @@ func (enc *ENC) GetNodegroup(nodegroupName string) (*Nodegroup, error) {
 	return &Nodegroup{}, fmt.Errorf("Nodegroup does not exist: %s", nodegroupName)
 }
 
+// NodegroupNames returns the sorted names of every nodegroup in the ENC
+func (enc *ENC) NodegroupNames() []string {
+	names := make([]string, 0, len(enc.Nodegroups))
+	for name := range enc.Nodegroups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// NodeNames returns the sorted, de-duplicated list of nodes in any nodegroup of the ENC
+func (enc *ENC) NodeNames() []string {
+	seen := make(map[string]bool)
+	names := make([]string, 0)
+	for _, nodegroup := range enc.Nodegroups {
+		for _, node := range nodegroup.Nodes {
+			if !seen[node] {
+				seen[node] = true
+				names = append(names, node)
+			}
+		}
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// ClassNames returns the sorted names of every class applied by a nodegroup in the ENC
+func (enc *ENC) ClassNames() []string {
+	seen := make(map[string]bool)
+	names := make([]string, 0)
+	for _, nodegroup := range enc.Nodegroups {
+		for class := range nodegroup.Classes {
+			if !seen[class] {
+				seen[class] = true
+				names = append(names, class)
+			}
+		}
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// NodeNodegroups returns the sorted names of the nodegroups that list a node directly
+func (enc *ENC) NodeNodegroups(nodeName string) []string {
+	names := make([]string, 0)
+	for name, nodegroup := range enc.Nodegroups {
+		for _, node := range nodegroup.Nodes {
+			if node == nodeName {
+				names = append(names, name)
+				break
+			}
+		}
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// ClassNodegroups returns the sorted names of the nodegroups that apply a class directly
+func (enc *ENC) ClassNodegroups(class string) []string {
+	names := make([]string, 0)
+	for name, nodegroup := range enc.Nodegroups {
+		if _, ok := nodegroup.Classes[class]; ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// IsOrphaned reports whether a nodegroup, or any of its ancestors, has a parent that doesn't exist
+func (enc *ENC) IsOrphaned(nodegroupName string) bool {
+	visited := make(map[string]bool)
+
+	nodegroup, err := enc.GetNodegroup(nodegroupName)
+	for err == nil && nodegroup.Parent != "" && !visited[nodegroup.Parent] {
+		visited[nodegroup.Parent] = true
+		nodegroup, err = enc.GetNodegroup(nodegroup.Parent)
+	}
+
+	return err != nil
+}
+
 // AddNode adds a single node to a nodegroup
 func (enc *ENC) AddNode(nodegroup string, nodeName string) (*Nodegroup, error) {
 	if _, ok := enc.Nodegroups[nodegroup]; !ok {
 		return &Nodegroup{}, errors.New("Nodegroup does not exist")
 	}
+	enc.addNodeChain(nodegroup, nodeName)
+
+	nodegroupObj, _ := enc.GetNodegroup(nodegroup)
+	nodegroupObj.Nodes = append(nodegroupObj.Nodes, nodeName)
+	enc.Nodegroups[nodegroup] = *nodegroupObj
+	return nodegroupObj, nil
+}
+
+// addNodeChain tracks the parent chain of a nodegroup for a node in the trie
+func (enc *ENC) addNodeChain(nodegroup string, nodeName string) {
 	parentChain := nodeName + CHAIN_SEPARATION_CHARACTER + strings.Join(reverse(enc.getParentChain(nodegroup)), CHAIN_SEPARATION_CHARACTER)
 
 	if _, ok := enc.Nodes.Find(nodeName); !ok {
 		enc.Nodes.Add(nodeName, nodeName)
 	}
 	enc.Nodes.Add(parentChain, parentChain)
-
-	nodegroupObj, _ := enc.GetNodegroup(nodegroup)
-	nodegroupObj.Nodes = append(nodegroupObj.Nodes, nodeName)
-	enc.Nodegroups[nodegroup] = *nodegroupObj
-	return nodegroupObj, nil
 }
 
 // AddNodes adds a slice of nodes to a nodegroup
@@ -182,6 +276,27 @@ func (enc *ENC) getLongestChain(node string) string {
 	return longest
 }
 
+// GetInheritedNodegroup retrieves a nodegroup with the classes, parameters and environment it
+// inherits from its parent chain merged in, the nodegroup itself winning, and references resolved
+func (enc *ENC) GetInheritedNodegroup(nodegroupName string) (*Nodegroup, error) {
+	nodegroup, err := enc.GetNodegroup(nodegroupName)
+	if err != nil {
+		return &Nodegroup{}, err
+	}
+
+	inherited := &Nodegroup{}
+	for _, ancestor := range enc.QualifiedParentChain(nodegroupName) {
+		ancestorNodegroup, err := enc.GetNodegroup(ancestor)
+		if err != nil {
+			return &Nodegroup{}, err
+		}
+		inherited = enc.mergeNodegroups(inherited, ancestorNodegroup)
+	}
+	inherited.Nodes = nodegroup.Nodes
+
+	return enc.resolveNodegroup(inherited, "", strings.Split(nodegroupName, "@")[0])
+}
+
 // RemoveNode removes a single node from a nodegroup
 func (enc *ENC) RemoveNode(nodegroup string, nodeName string) (*Nodegroup, error) {
 	if _, ok := enc.Nodegroups[nodegroup]; !ok {
@@ -195,7 +310,8 @@ func (enc *ENC) RemoveNode(nodegroup string, nodeName string) (*Nodegroup, error
 
 	children := enc.Nodes.FuzzySearch(nodegroup + CHAIN_SEPARATION_CHARACTER)
 	if len(children) == 0 {
-		chains := enc.Nodes.PrefixSearch(nodeName)
+		// Prefix search also finds longer node names, e.g. node-00010 for node-0001
+		chains := enc.Nodes.PrefixSearch(nodeName + CHAIN_SEPARATION_CHARACTER)
 		for _, chain := range chains {
 
 			if strings.HasSuffix(chain, nodegroup) {
@@ -207,17 +323,33 @@ func (enc *ENC) RemoveNode(nodegroup string, nodeName string) (*Nodegroup, error
 
 	nodegroupObj, _ := enc.GetNodegroup(nodegroup)
 	nodegroupObj.Nodes = removeByValueSS(nodegroupObj.Nodes, nodeName)
+	enc.Nodegroups[strings.Split(nodegroup, "@")[0]] = *nodegroupObj
 	return nodegroupObj, nil
 }
 
-// GetNode retrieves a nodegroup that represents all inherited values for a node
+// GetNode retrieves a nodegroup that represents all inherited values for a node, with secrets
+// decrypted and references like %{param.datacenter} in its parameters and class parameters resolved
 func (enc *ENC) GetNode(nodeName string) (*Nodegroup, error) {
+	masterNodegroup, err := enc.mergedNode(nodeName)
+	if err != nil {
+		return &Nodegroup{}, err
+	}
+
+	// Interpolate once everything's merged, so values can reference ones they inherit
+	return enc.resolveNodegroup(masterNodegroup, nodeName, strings.Join(enc.NodeNodegroups(nodeName), ","))
+}
+
+// mergedNode merges every nodegroup a node inherits values from, leaving secrets and references
+// as they are
+func (enc *ENC) mergedNode(nodeName string) (*Nodegroup, error) {
 	var (
 		matchedNodegroups []*Nodegroup
 	)
 
 	chains, err := enc.GetChains(nodeName)
-	errCheck(err)
+	if err != nil {
+		return &Nodegroup{}, err
+	}
 
 	commonChain, alteredChains := enc.findCommonChain(chains)
 	masterNodegroup := &Nodegroup{}
@@ -231,13 +363,27 @@ func (enc *ENC) GetNode(nodeName string) (*Nodegroup, error) {
 
 	if len(matchedNodegroups) > 1 {
 		masterNodegroup, err = enc.ConflictMerge(matchedNodegroups)
-		errCheck(err)
+		if err != nil {
+			return &Nodegroup{}, err
+		}
 	}
 
 	// Finally, get the info for the common chain and merge the final data onto it
-	masterNodegroup = enc.mergeNodegroups(enc.getMergedChainNodegroup(commonChain), masterNodegroup)
+	return enc.mergeNodegroups(enc.getMergedChainNodegroup(commonChain), masterNodegroup), nil
+}
 
-	return masterNodegroup, nil
+// resolveNodegroup decrypts the secrets in a merged nodegroup, when the config has keys loaded,
+// then interpolates its references
+func (enc *ENC) resolveNodegroup(nodegroup *Nodegroup, nodeName string, nodegroupName string) (*Nodegroup, error) {
+	if enc.ConfigLink != nil && enc.ConfigLink.SecretKeys != nil {
+		decrypted, err := enc.ConfigLink.SecretKeys.decryptNodegroup(nodegroup)
+		if err != nil {
+			return &Nodegroup{}, err
+		}
+		nodegroup = decrypted
+	}
+
+	return interpolateNodegroup(nodegroup, nodeName, nodegroupName)
 }
 
 func (enc *ENC) getMergedChainNodegroup(chain string) *Nodegroup {
@@ -434,6 +580,7 @@ func (enc *ENC) AddClass(nodegroupName string, key string) (*Nodegroup, error) {
 
 	nodegroup.Classes[key] = make(map[string]interface{})
 	enc.Nodegroups[nodegroupName] = *nodegroup
+	enc.checkCatalog(key, "")
 	return nodegroup, nil
 }
 
@@ -463,9 +610,13 @@ func (enc *ENC) AddClassParameter(nodegroupName string, class string, key string
 		return &Nodegroup{}, errors.New("Class does not exist on that nodegroup")
 	}
 
-	ngClass := nodegroup.Classes[class].(map[string]interface{})
+	ngClass := copyValue(nodegroup.Classes[class].(map[string]interface{})).(map[string]interface{})
 	ngClass[key] = val
+	if err := enc.checkClassSchema(class, ngClass); err != nil {
+		return &Nodegroup{}, err
+	}
 	nodegroup.Classes[class] = ngClass
+	enc.checkCatalog(class, key)
 
 	return nodegroup, nil
 }
@@ -521,6 +672,12 @@ func (enc *ENC) SetEnvironment(nodegroupName string, env string) (*Nodegroup, er
 		return &Nodegroup{}, err
 	}
 
+	if enc.ConfigLink != nil {
+		if err := enc.ConfigLink.CheckEnvironment(env); err != nil {
+			return &Nodegroup{}, err
+		}
+	}
+
 	nodegroup.Environment = env
 	enc.Nodegroups[nodegroupName] = *nodegroup
 	return nodegroup, nil
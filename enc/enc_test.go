@@ -381,6 +381,10 @@ func TestRemoveNode(t *testing.T) {
 	assert.Nil(gotEnc.Nodes.Find("node-0001$$wantNodegroup@enc_test-json_data$$subNodegroup@enc_test-json_data"))
 
 	// We aren't testing the trie package
+	wantNodegroup.Nodes = []string{}
+	subNodegroup.Nodes = []string{}
+	wantEnc.Nodegroups["wantNodegroup"] = wantNodegroup
+	wantEnc.Nodegroups["subNodegroup"] = subNodegroup
 	wantEnc.Nodes = gotEnc.Nodes
 	assert.Equal(wantEnc, *gotEnc)
 }
@@ -804,3 +808,32 @@ func TestGetNode(t *testing.T) {
 	assert.Nil(gotErr)
 	assert.Equal(wantNode, *gotNode)
 }
+
+func TestENCAccessors(t *testing.T) {
+	assert := assert.New(t)
+	c := newFixtureConfig()
+	production := c.ENCs["production"]
+
+	assert.Equal([]string{"database", "globals", "website", "website_canary"}, production.NodegroupNames())
+	assert.Equal([]string{"db-0001", "web-0001", "web-0002"}, production.NodeNames())
+	assert.Equal([]string{"mysql", "nginx", "ntp"}, production.ClassNames())
+	assert.Equal([]string{"website", "website_canary"}, production.NodeNodegroups("web-0001"))
+	assert.Equal([]string{"website", "website_canary"}, production.ClassNodegroups("nginx"))
+	assert.Equal([]string{}, production.NodeNodegroups("web-9999"))
+}
+
+func TestIsOrphaned(t *testing.T) {
+	assert := assert.New(t)
+	c := newFixtureConfig()
+	production := c.ENCs["production"]
+
+	production.AddNodegroup("lost", "missing@production", map[string]interface{}{}, []string{}, map[string]interface{}{})
+	production.AddNodegroup("lost_child", "lost@production", map[string]interface{}{}, []string{}, map[string]interface{}{})
+	production.AddNodegroup("lost_cluster", "globals@missing", map[string]interface{}{}, []string{}, map[string]interface{}{})
+
+	assert.False(production.IsOrphaned("website_canary"))
+	assert.False(c.ENCs["staging"].IsOrphaned("staging_web"))
+	assert.True(production.IsOrphaned("lost"))
+	assert.True(production.IsOrphaned("lost_child"))
+	assert.True(production.IsOrphaned("lost_cluster"))
+}
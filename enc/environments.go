@@ -0,0 +1,115 @@
+package enc
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+)
+
+// Promotion is what promoting a nodegroup, or some of its nodes, to another environment changed
+type Promotion struct {
+	// Nodegroup is the nodegroup whose environment was set, the child the nodes were moved to if any
+	Nodegroup string                 `json:"nodegroup" yaml:"nodegroup"`
+	From      string                 `json:"from" yaml:"from"`
+	To        string                 `json:"to" yaml:"to"`
+	Nodes     []string               `json:"nodes,omitempty" yaml:"nodes,omitempty"`
+	Changes   []ClassificationChange `json:"changes" yaml:"changes"`
+}
+
+// LoadEnvironments returns the names of the directories in dir, e.g. a Puppet code directory's
+// environments/, as the allowed environments
+func LoadEnvironments(dir string) ([]string, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return []string{}, err
+	}
+
+	environments := make([]string, 0, len(files))
+	for _, file := range files {
+		if file.IsDir() && !strings.HasPrefix(file.Name(), ".") {
+			environments = append(environments, file.Name())
+		}
+	}
+	sort.Strings(environments)
+
+	return environments, nil
+}
+
+// CheckEnvironment returns an error if environments are restricted and env isn't one of them. An
+// empty environment, which unsets it, is always allowed.
+func (c *Config) CheckEnvironment(env string) error {
+	if c.Environments == nil || env == "" {
+		return nil
+	}
+
+	for _, allowed := range c.Environments {
+		if allowed == env {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("Unknown environment %s, expecting %s", env, strings.Join(c.Environments, "|"))
+}
+
+// Promote moves a nodegroup to another environment. Given nodes, only those are: they're moved into
+// a child nodegroup in that environment, named childName or <nodegroup>_<environment>, which is
+// created if it doesn't exist. It reports the classification of every node that changed, in any
+// ENC.
+func (enc *ENC) Promote(nodegroupName string, env string, nodes []string, childName string) (*Promotion, error) {
+	if enc.ConfigLink != nil {
+		if err := enc.ConfigLink.CheckEnvironment(env); err != nil {
+			return &Promotion{}, err
+		}
+	}
+
+	inherited, err := enc.GetInheritedNodegroup(nodegroupName)
+	if err != nil {
+		return &Promotion{}, err
+	}
+	nodegroup, _ := enc.GetNodegroup(nodegroupName)
+
+	promotion := &Promotion{Nodegroup: nodegroupName, From: inherited.Environment, To: env, Nodes: nodes}
+	if len(nodes) == 0 && inherited.Environment == env {
+		return &Promotion{}, fmt.Errorf("Nodegroup %s is already in environment %s", nodegroupName, env)
+	}
+
+	for _, node := range nodes {
+		if !containsString(nodegroup.Nodes, node) {
+			return &Promotion{}, fmt.Errorf("Node %s is not in nodegroup %s", node, nodegroupName)
+		}
+	}
+
+	// Nodes of other ENCs can inherit the environment too
+	before := enc.snapshotLinkedClassifications()
+
+	if len(nodes) == 0 {
+		if _, err := enc.SetEnvironment(nodegroupName, env); err != nil {
+			return &Promotion{}, err
+		}
+	} else {
+		if childName == "" {
+			childName = nodegroupName + "_" + env
+		}
+		promotion.Nodegroup = childName
+
+		if child, err := enc.GetNodegroup(childName); err != nil {
+			if _, err := enc.AddNodegroup(childName, QualifyNodegroup(nodegroupName, enc.Name), map[string]interface{}{}, []string{}, map[string]interface{}{}); err != nil {
+				return &Promotion{}, err
+			}
+		} else if child.Parent != QualifyNodegroup(nodegroupName, enc.Name) && child.Parent != nodegroupName {
+			return &Promotion{}, fmt.Errorf("Nodegroup %s already exists and isn't a child of %s", childName, nodegroupName)
+		}
+
+		if _, err := enc.SetEnvironment(childName, env); err != nil {
+			return &Promotion{}, err
+		}
+
+		if err := enc.moveNodesWithin(nodegroupName, childName, nodes); err != nil {
+			return &Promotion{}, err
+		}
+	}
+
+	promotion.Changes = diffClassifications(before, enc.snapshotLinkedClassifications())
+	return promotion, nil
+}
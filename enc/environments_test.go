@@ -0,0 +1,155 @@
+package enc
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadEnvironments(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "go-enc-environments")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, name := range []string{"production", "canary", ".git"} {
+		os.Mkdir(filepath.Join(dir, name), 0755)
+	}
+	ioutil.WriteFile(filepath.Join(dir, "README.md"), []byte("Environments"), 0644)
+
+	environments, err := LoadEnvironments(dir)
+	assert.Nil(err)
+	assert.Equal([]string{"canary", "production"}, environments)
+}
+
+func TestCheckEnvironment(t *testing.T) {
+	assert := assert.New(t)
+	c := newFixtureConfig()
+	production := c.ENCs["production"]
+
+	// Anything goes until environments are restricted
+	_, err := production.SetEnvironment("database", "testing")
+	assert.Nil(err)
+
+	c.Environments = []string{"production", "canary"}
+	_, err = production.SetEnvironment("database", "staging")
+	assert.EqualError(err, "Unknown environment staging, expecting production|canary")
+	assert.Equal("testing", production.Nodegroups["database"].Environment)
+
+	_, err = production.SetEnvironment("database", "")
+	assert.Nil(err)
+}
+
+func TestPromoteNodegroup(t *testing.T) {
+	assert := assert.New(t)
+	c := newFixtureConfig()
+	c.Environments = []string{"production", "canary"}
+	production := c.ENCs["production"]
+
+	_, err := production.Promote("website", "staging", []string{}, "")
+	assert.EqualError(err, "Unknown environment staging, expecting production|canary")
+
+	_, err = production.Promote("website", "production", []string{}, "")
+	assert.EqualError(err, "Nodegroup website is already in environment production")
+
+	// web-0001 is already in canary through website_canary, so only web-0002 changes, and
+	// web-0101 in staging once staging_web inherits the environment
+	c.ENCs["staging"].SetEnvironment("staging_web", "")
+	promotion, err := production.Promote("website", "canary", []string{}, "")
+	assert.Nil(err)
+	assert.Equal(&Promotion{
+		Nodegroup: "website",
+		From:      "production",
+		To:        "canary",
+		Nodes:     []string{},
+		Changes: []ClassificationChange{
+			{Node: "web-0002", Field: "environment", Change: "changed", Before: "production", After: "canary"},
+			{Node: "web-0101", Field: "environment", Change: "changed", Before: "production", After: "canary"},
+		},
+	}, promotion)
+	assert.Equal("canary", production.Nodegroups["website"].Environment)
+}
+
+func TestPromoteNodes(t *testing.T) {
+	assert := assert.New(t)
+	c := newFixtureConfig()
+	production := c.ENCs["production"]
+	production.AddNode("database", "db-0002")
+
+	_, err := production.Promote("database", "canary", []string{"web-0001"}, "")
+	assert.EqualError(err, "Node web-0001 is not in nodegroup database")
+
+	_, err = production.Promote("database", "canary", []string{"db-0002"}, "website")
+	assert.EqualError(err, "Nodegroup website already exists and isn't a child of database")
+
+	promotion, err := production.Promote("database", "canary", []string{"db-0002"}, "")
+	assert.Nil(err)
+	assert.Equal("database_canary", promotion.Nodegroup)
+	assert.Equal([]ClassificationChange{
+		{Node: "db-0002", Field: "environment", Change: "changed", Before: "production", After: "canary"},
+	}, promotion.Changes)
+
+	child := production.Nodegroups["database_canary"]
+	assert.Equal("database@production", child.Parent)
+	assert.Equal("canary", child.Environment)
+	assert.Equal([]string{"db-0002"}, child.Nodes)
+	assert.Equal([]string{"db-0001"}, production.Nodegroups["database"].Nodes)
+
+	node, err := production.GetNode("db-0002")
+	assert.Nil(err)
+	assert.Contains(node.Classes, "mysql")
+	assert.Equal("canary", node.Environment)
+}
+
+func TestDiffClassifications(t *testing.T) {
+	assert := assert.New(t)
+	c := newFixtureConfig()
+	production := c.ENCs["production"]
+
+	before := production.snapshotClassifications()
+	production.SetClassParameter("website", "nginx", "worker_processes", 4)
+	production.AddClass("website", "haproxy")
+	production.RemoveParameter("globals", "datacenter")
+
+	assert.Equal([]ClassificationChange{
+		{Node: "db-0001", Field: "param.datacenter", Change: "removed", Before: "dub1"},
+		{Node: "web-0001", Field: "class.haproxy", Change: "added"},
+		{Node: "web-0001", Field: "param.datacenter", Change: "removed", Before: "dub1"},
+		{Node: "web-0002", Field: "class.haproxy", Change: "added"},
+		{Node: "web-0002", Field: "class.nginx.worker_processes", Change: "changed", Before: "8", After: "4"},
+		{Node: "web-0002", Field: "param.datacenter", Change: "removed", Before: "dub1"},
+	}, diffClassifications(before, production.snapshotClassifications()))
+}
+
+func TestDiffClassificationsHidesSecrets(t *testing.T) {
+	assert := assert.New(t)
+	keys, _, cleanup := newTestSecretKeys(t, "prod")
+	defer cleanup()
+
+	c := newFixtureConfig()
+	c.SecretKeys = keys
+	production := c.ENCs["production"]
+
+	secret, _ := keys.Encrypt("hunter2", "prod")
+	production.SetParameterPath("globals", "db.password", secret)
+	production.SetParameterPath("database", "db.dsn", "mysql://app:%{param.db.password}@db")
+
+	before := production.snapshotClassifications()
+	rotated, _ := keys.Encrypt("hunter3", "prod")
+	production.SetParameterPath("globals", "db.password", rotated)
+	production.SetParameterPath("database", "db.password", "swordfish")
+
+	changes := diffClassifications(before, production.snapshotClassifications())
+	assert.Contains(changes, ClassificationChange{Node: "db-0001", Field: "param.db.dsn", Change: "changed", Before: EncryptedValue, After: "mysql://app:swordfish@db"})
+	assert.Contains(changes, ClassificationChange{Node: "db-0001", Field: "param.db.password", Change: "changed", Before: EncryptedValue, After: "swordfish"})
+	assert.Contains(changes, ClassificationChange{Node: "web-0001", Field: "param.db.password", Change: "changed", Before: EncryptedValue, After: EncryptedValue})
+	for _, change := range changes {
+		assert.NotContains(change.Before, "hunter")
+		assert.NotContains(change.After, "hunter")
+	}
+}
@@ -0,0 +1,107 @@
+package enc
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// NodegroupFields are the keys of a nodegroup go-enc understands, anything else ends up in Extra
+var NodegroupFields = []string{"parent", "classes", "nodes", "parameters", "environment"}
+
+func knownNodegroupField(key string) bool {
+	for _, field := range NodegroupFields {
+		if key == field {
+			return true
+		}
+	}
+
+	return false
+}
+
+// MarshalJSON writes the nodegroup's fields followed by its Extra keys
+func (nodegroup Nodegroup) MarshalJSON() ([]byte, error) {
+	type plainNodegroup Nodegroup
+	contents, err := json.Marshal(plainNodegroup(nodegroup))
+	if err != nil || len(nodegroup.Extra) == 0 {
+		return contents, err
+	}
+
+	extra, err := json.Marshal(nodegroup.Extra)
+	if err != nil {
+		return []byte{}, err
+	}
+
+	if len(contents) == 2 {
+		return extra, nil
+	}
+
+	contents = append(contents[:len(contents)-1], ',')
+	return append(contents, extra[1:]...), nil
+}
+
+// nodegroupValues is a nodegroup as plain values, leaving out what Nodegroup's omitempty would
+func nodegroupValues(nodegroup Nodegroup) map[string]interface{} {
+	values := make(map[string]interface{}, len(nodegroup.Extra))
+	for key, value := range nodegroup.Extra {
+		values[key] = value
+	}
+
+	if nodegroup.Parent != "" {
+		values["parent"] = nodegroup.Parent
+	}
+	if len(nodegroup.Classes) > 0 {
+		values["classes"] = nodegroup.Classes
+	}
+	if len(nodegroup.Nodes) > 0 {
+		nodes := make([]interface{}, 0, len(nodegroup.Nodes))
+		for _, node := range nodegroup.Nodes {
+			nodes = append(nodes, node)
+		}
+		values["nodes"] = nodes
+	}
+	if len(nodegroup.Parameters) > 0 {
+		values["parameters"] = nodegroup.Parameters
+	}
+	if nodegroup.Environment != "" {
+		values["environment"] = nodegroup.Environment
+	}
+
+	return values
+}
+
+// UnknownFields lists the Extra keys of every nodegroup, by "nodegroup@enc"
+func (c *Config) UnknownFields() map[string][]string {
+	unknown := make(map[string][]string)
+	for _, encName := range c.ENCNames() {
+		for name, nodegroup := range c.ENCs[encName].Nodegroups {
+			if len(nodegroup.Extra) > 0 {
+				unknown[name+"@"+encName] = sortedKeys(nodegroup.Extra)
+			}
+		}
+	}
+
+	return unknown
+}
+
+// CheckStrict returns an error naming every nodegroup with keys go-enc doesn't know about
+func (c *Config) CheckStrict() error {
+	unknown := c.UnknownFields()
+	if len(unknown) == 0 {
+		return nil
+	}
+
+	names := make([]string, 0, len(unknown))
+	for name := range unknown {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	problems := make([]string, 0, len(names))
+	for _, name := range names {
+		problems = append(problems, fmt.Sprintf("%s (%s)", name, strings.Join(unknown[name], ", ")))
+	}
+
+	return fmt.Errorf("Unknown nodegroup fields, expecting %s: %s", strings.Join(NodegroupFields, "|"), strings.Join(problems, "; "))
+}
@@ -0,0 +1,107 @@
+package enc
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeExtraFieldsENC(t *testing.T, format string) (string, func()) {
+	dir, err := ioutil.TempDir("", "go-enc-fields")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	contents, err := EncodeENC(format, map[string]Nodegroup{
+		"website": Nodegroup{
+			Parent:      "globals@production",
+			Environment: "canary",
+			Extra: map[string]interface{}{
+				"owner":      "web-team",
+				"tags":       []interface{}{"public", "tier-1"},
+				"monitoring": map[string]interface{}{"pager": true},
+			},
+		},
+		"globals": Nodegroup{
+			Parameters: map[string]interface{}{"datacenter": "dub1"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fileName := filepath.Join(dir, "production."+format)
+	if err := ioutil.WriteFile(fileName, contents, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return fileName, func() { os.RemoveAll(dir) }
+}
+
+func TestLoadEnvironmentAndExtra(t *testing.T) {
+	for _, format := range Formats {
+		assert := assert.New(t)
+		fileName, cleanup := writeExtraFieldsENC(t, format)
+		defer cleanup()
+
+		website := NewConfig(fileName).ENCs["production"].Nodegroups["website"]
+		assert.Equal("canary", website.Environment, format)
+		assert.Equal("globals@production", website.Parent, format)
+		assert.Equal("web-team", website.Extra["owner"], format)
+		assert.Equal([]interface{}{"public", "tier-1"}, website.Extra["tags"], format)
+		assert.Equal(map[string]interface{}{"pager": true}, website.Extra["monitoring"], format)
+		assert.Nil(website.Extra["environment"], format)
+	}
+}
+
+func TestExtraRoundTrip(t *testing.T) {
+	for _, format := range Formats {
+		assert := assert.New(t)
+		fileName, cleanup := writeExtraFieldsENC(t, format)
+		defer cleanup()
+
+		config := NewConfig(fileName)
+		config.ENCs["production"].SetEnvironment("globals", "production")
+		config.WriteOutENC()
+
+		reloaded := NewConfig(fileName).ENCs["production"]
+		assert.Equal("production", reloaded.Nodegroups["globals"].Environment, format)
+		assert.Equal("canary", reloaded.Nodegroups["website"].Environment, format)
+		assert.Equal(config.ENCs["production"].Nodegroups["website"].Extra, reloaded.Nodegroups["website"].Extra, format)
+		assert.Nil(reloaded.Nodegroups["globals"].Extra, format)
+	}
+}
+
+func TestNodegroupMarshalJSON(t *testing.T) {
+	assert := assert.New(t)
+
+	contents, err := Nodegroup{Parent: "globals", Extra: map[string]interface{}{"owner": "ops"}}.MarshalJSON()
+	assert.Nil(err)
+	assert.Equal(`{"parent":"globals","owner":"ops"}`, string(contents))
+
+	contents, err = Nodegroup{Extra: map[string]interface{}{"owner": "ops"}}.MarshalJSON()
+	assert.Nil(err)
+	assert.Equal(`{"owner":"ops"}`, string(contents))
+
+	contents, err = Nodegroup{Parent: "globals"}.MarshalJSON()
+	assert.Nil(err)
+	assert.Equal(`{"parent":"globals"}`, string(contents))
+}
+
+func TestCheckStrict(t *testing.T) {
+	assert := assert.New(t)
+	fileName, cleanup := writeExtraFieldsENC(t, "yaml")
+	defer cleanup()
+
+	config := NewConfig(fileName)
+	assert.Equal(map[string][]string{"website@production": {"monitoring", "owner", "tags"}}, config.UnknownFields())
+
+	err := config.CheckStrict()
+	assert.NotNil(err)
+	assert.Contains(err.Error(), "website@production (monitoring, owner, tags)")
+
+	assert.Nil(newFixtureConfig().CheckStrict())
+}
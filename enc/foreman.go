@@ -0,0 +1,377 @@
+package enc
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// ForemanImport reports what an import from Foreman created and what it couldn't map
+type ForemanImport struct {
+	Nodegroups []string `json:"nodegroups" yaml:"nodegroups"`
+	Nodes      []string `json:"nodes" yaml:"nodes"`
+	Unmapped   []string `json:"unmapped" yaml:"unmapped"`
+}
+
+// foremanHostgroup is the part of a Foreman host group we can map onto a nodegroup
+type foremanHostgroup struct {
+	id          string
+	name        string
+	title       string
+	parentID    string
+	parentTitle string
+	environment string
+	classes     map[string]interface{}
+	parameters  map[string]interface{}
+	nodegroup   string
+}
+
+// ImportForeman creates nodegroups from a Foreman host group export and adds the nodes from a
+// Foreman host export. Both are JSON as returned by the Foreman API, either the list itself or
+// an object with a "results" list, and hostData may be empty.
+//
+// Host groups map to nodegroups named after the host group, or its title with "/" replaced by
+// "_" when names clash, with their parent, environment, Puppet classes, smart class parameter
+// values and parameters. Hosts are added to the nodegroup of their host group. Anything that
+// has no equivalent, like per-host classes or parameters, is listed in Unmapped.
+func (enc *ENC) ImportForeman(hostgroupData []byte, hostData []byte) (*ForemanImport, error) {
+	report := &ForemanImport{Nodegroups: []string{}, Nodes: []string{}, Unmapped: []string{}}
+
+	rawHostgroups, err := foremanResults(hostgroupData)
+	if err != nil {
+		return report, fmt.Errorf("Could not read Foreman host groups: %s", err)
+	}
+
+	rawHosts := []map[string]interface{}{}
+	if len(strings.TrimSpace(string(hostData))) > 0 {
+		if rawHosts, err = foremanResults(hostData); err != nil {
+			return report, fmt.Errorf("Could not read Foreman hosts: %s", err)
+		}
+	}
+
+	hostgroups := make([]*foremanHostgroup, 0, len(rawHostgroups))
+	nameCounts := make(map[string]int)
+	for _, raw := range rawHostgroups {
+		hostgroup := report.parseForemanHostgroup(raw)
+		if hostgroup.name == "" {
+			report.unmapped("Host group without a name: %v", raw)
+			continue
+		}
+
+		hostgroups = append(hostgroups, hostgroup)
+		nameCounts[hostgroup.name]++
+	}
+
+	byID := make(map[string]*foremanHostgroup)
+	byTitle := make(map[string]*foremanHostgroup)
+	byName := make(map[string]*foremanHostgroup)
+	for _, hostgroup := range hostgroups {
+		hostgroup.nodegroup = hostgroup.name
+		if nameCounts[hostgroup.name] > 1 {
+			hostgroup.nodegroup = strings.Replace(hostgroup.title, "/", "_", -1)
+		} else {
+			byName[hostgroup.name] = hostgroup
+		}
+
+		if hostgroup.id != "" {
+			byID[hostgroup.id] = hostgroup
+		}
+		byTitle[hostgroup.title] = hostgroup
+	}
+
+	created := make(map[string]bool)
+	for _, hostgroup := range hostgroups {
+		parent := ""
+		if parentGroup := foremanParent(hostgroup, byID, byTitle); parentGroup != nil {
+			parent = parentGroup.nodegroup + "@" + enc.Name
+		} else if hostgroup.parentID != "" || hostgroup.parentTitle != "" {
+			report.unmapped("Parent of host group %s is not in the export", hostgroup.title)
+		}
+
+		if _, err := enc.AddNodegroup(hostgroup.nodegroup, parent, hostgroup.classes, []string{}, hostgroup.parameters); err != nil {
+			report.unmapped("Host group %s not imported: %s", hostgroup.title, err)
+			continue
+		}
+		created[hostgroup.title] = true
+
+		if hostgroup.environment != "" {
+			if _, err := enc.SetEnvironment(hostgroup.nodegroup, hostgroup.environment); err != nil {
+				report.unmapped("Environment of host group %s not imported: %s", hostgroup.title, err)
+			}
+		}
+
+		report.Nodegroups = append(report.Nodegroups, hostgroup.nodegroup)
+	}
+
+	for _, raw := range rawHosts {
+		name := foremanString(raw, "certname", "name")
+		if name == "" {
+			report.unmapped("Host without a name: %v", raw)
+			continue
+		}
+
+		hostgroup := foremanParent(&foremanHostgroup{
+			parentID:    foremanString(raw, "hostgroup_id"),
+			parentTitle: foremanString(raw, "hostgroup_title"),
+		}, byID, byTitle)
+		if hostgroup == nil {
+			hostgroup = byName[foremanString(raw, "hostgroup_name")]
+		}
+
+		if hostgroup == nil || !created[hostgroup.title] {
+			report.unmapped("Host %s has no imported host group", name)
+			continue
+		}
+
+		if _, err := enc.AddNode(hostgroup.nodegroup, name); err != nil {
+			report.unmapped("Host %s not imported: %s", name, err)
+			continue
+		}
+		report.Nodes = append(report.Nodes, name)
+
+		// Nodegroups carry classes, parameters and environments, nodes can't have their own
+		if len(foremanList(raw, "puppetclasses")) > 0 {
+			report.unmapped("Host %s has its own Puppet classes", name)
+		}
+		if len(foremanList(raw, "parameters", "host_parameters")) > 0 {
+			report.unmapped("Host %s has its own parameters", name)
+		}
+		if environment := foremanString(raw, "environment_name"); environment != "" && environment != hostgroup.environment {
+			report.unmapped("Host %s uses environment %s instead of its host group's", name, environment)
+		}
+	}
+
+	return report, nil
+}
+
+func (report *ForemanImport) unmapped(format string, args ...interface{}) {
+	report.Unmapped = append(report.Unmapped, fmt.Sprintf(format, args...))
+}
+
+func (report *ForemanImport) parseForemanHostgroup(raw map[string]interface{}) *foremanHostgroup {
+	hostgroup := &foremanHostgroup{
+		id:          foremanString(raw, "id"),
+		name:        foremanString(raw, "name"),
+		title:       foremanString(raw, "title"),
+		parentID:    foremanString(raw, "parent_id"),
+		environment: foremanString(raw, "environment_name"),
+		classes:     make(map[string]interface{}),
+		parameters:  make(map[string]interface{}),
+	}
+
+	if hostgroup.title == "" {
+		hostgroup.title = hostgroup.name
+	}
+
+	if index := strings.LastIndex(hostgroup.title, "/"); index != -1 {
+		hostgroup.parentTitle = hostgroup.title[:index]
+	}
+
+	if environment, ok := raw["environment"].(map[string]interface{}); ok && hostgroup.environment == "" {
+		hostgroup.environment = foremanString(environment, "name")
+	}
+
+	for _, class := range foremanClassNames(raw["puppetclasses"]) {
+		hostgroup.classes[class] = make(map[string]interface{})
+	}
+
+	for _, item := range foremanList(raw, "parameters", "group_parameters") {
+		name := foremanString(item, "name")
+		value, err := foremanValue(item)
+		if err != nil {
+			report.unmapped("Parameter %s of host group %s kept as a string: %s", name, hostgroup.title, err)
+		}
+		hostgroup.parameters[name] = value
+	}
+
+	for _, item := range foremanList(raw, "smart_class_parameters") {
+		report.parseSmartClassParameter(hostgroup, item)
+	}
+
+	return hostgroup
+}
+
+// parseSmartClassParameter maps a smart class parameter's value for this host group onto the
+// class body, either its own "value" or an override value matching the host group
+func (report *ForemanImport) parseSmartClassParameter(hostgroup *foremanHostgroup, item map[string]interface{}) {
+	name := foremanString(item, "parameter", "name")
+	class := foremanString(item, "puppetclass_name")
+	if puppetclass, ok := item["puppetclass"].(map[string]interface{}); ok && class == "" {
+		class = foremanString(puppetclass, "name")
+	}
+
+	if name == "" || class == "" {
+		report.unmapped("Smart class parameter of host group %s without a name or class: %v", hostgroup.title, item)
+		return
+	}
+
+	valueItem := item
+	if _, hasValue := item["value"]; !hasValue {
+		valueItem = nil
+		for _, override := range foremanList(item, "override_values") {
+			match := foremanString(override, "match")
+			if match == "hostgroup="+hostgroup.title || match == "hostgroup="+hostgroup.name {
+				valueItem = map[string]interface{}{
+					"value":          override["value"],
+					"parameter_type": item["parameter_type"],
+					"key_type":       item["key_type"],
+				}
+			}
+		}
+	}
+
+	if valueItem == nil {
+		report.unmapped("Smart class parameter %s::%s has no value for host group %s", class, name, hostgroup.title)
+		return
+	}
+
+	value, err := foremanValue(valueItem)
+	if err != nil {
+		report.unmapped("Smart class parameter %s::%s of host group %s kept as a string: %s", class, name, hostgroup.title, err)
+	}
+
+	classBody, ok := hostgroup.classes[class].(map[string]interface{})
+	if !ok {
+		classBody = make(map[string]interface{})
+		hostgroup.classes[class] = classBody
+	}
+	classBody[name] = value
+}
+
+// foremanParent finds the host group referenced by another's parent ID or title
+func foremanParent(hostgroup *foremanHostgroup, byID map[string]*foremanHostgroup, byTitle map[string]*foremanHostgroup) *foremanHostgroup {
+	if parent, ok := byID[hostgroup.parentID]; ok && hostgroup.parentID != "" {
+		return parent
+	}
+
+	if parent, ok := byTitle[hostgroup.parentTitle]; ok && hostgroup.parentTitle != "" {
+		return parent
+	}
+
+	return nil
+}
+
+// foremanResults accepts either a JSON list or an API response with a "results" list
+func foremanResults(data []byte) ([]map[string]interface{}, error) {
+	var raw interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return []map[string]interface{}{}, err
+	}
+
+	if response, ok := raw.(map[string]interface{}); ok {
+		raw = response["results"]
+	}
+
+	items, ok := raw.([]interface{})
+	if !ok {
+		return []map[string]interface{}{}, fmt.Errorf("expecting a list or an object with a \"results\" list")
+	}
+
+	results := make([]map[string]interface{}, 0, len(items))
+	for _, item := range items {
+		if itemMap, ok := item.(map[string]interface{}); ok {
+			results = append(results, itemMap)
+		}
+	}
+
+	return results, nil
+}
+
+// foremanString returns the first of keys that's set, as a string
+func foremanString(raw map[string]interface{}, keys ...string) string {
+	for _, key := range keys {
+		switch val := raw[key].(type) {
+		case string:
+			if val != "" {
+				return val
+			}
+		case float64:
+			return strconv.FormatFloat(val, 'f', -1, 64)
+		}
+	}
+
+	return ""
+}
+
+// foremanList returns the first of keys that's a list of objects
+func foremanList(raw map[string]interface{}, keys ...string) []map[string]interface{} {
+	for _, key := range keys {
+		if items, ok := raw[key].([]interface{}); ok {
+			list := make([]map[string]interface{}, 0, len(items))
+			for _, item := range items {
+				if itemMap, ok := item.(map[string]interface{}); ok {
+					list = append(list, itemMap)
+				}
+			}
+			return list
+		}
+	}
+
+	return []map[string]interface{}{}
+}
+
+// foremanClassNames reads Puppet classes given as a list of names, a list of objects or the
+// module -> classes map the Foreman API returns
+func foremanClassNames(raw interface{}) []string {
+	names := make([]string, 0)
+
+	switch classes := raw.(type) {
+	case []interface{}:
+		for _, class := range classes {
+			switch classVal := class.(type) {
+			case string:
+				names = append(names, classVal)
+			case map[string]interface{}:
+				if name := foremanString(classVal, "name"); name != "" {
+					names = append(names, name)
+				}
+			}
+		}
+	case map[string]interface{}:
+		for _, moduleClasses := range classes {
+			names = append(names, foremanClassNames(moduleClasses)...)
+		}
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// foremanValue converts a Foreman parameter value using its parameter_type (or key_type)
+func foremanValue(item map[string]interface{}) (interface{}, error) {
+	value := item["value"]
+	stringValue, isString := value.(string)
+	if !isString {
+		return value, nil
+	}
+
+	var err error
+	switch foremanString(item, "parameter_type", "key_type") {
+	case "integer":
+		var intValue int
+		if intValue, err = strconv.Atoi(stringValue); err == nil {
+			return intValue, nil
+		}
+	case "real":
+		var floatValue float64
+		if floatValue, err = strconv.ParseFloat(stringValue, 64); err == nil {
+			return floatValue, nil
+		}
+	case "boolean":
+		var boolValue bool
+		if boolValue, err = strconv.ParseBool(stringValue); err == nil {
+			return boolValue, nil
+		}
+	case "array", "hash", "json", "yaml":
+		var parsed interface{}
+		if err = yaml.Unmarshal([]byte(stringValue), &parsed); err == nil {
+			return stringifyYAMLMapKeys(parsed), nil
+		}
+	}
+
+	return stringValue, err
+}
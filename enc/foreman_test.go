@@ -0,0 +1,118 @@
+package enc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var foremanHostgroupData = `
+{
+  "total": 4,
+  "results": [
+    {
+      "id": 1,
+      "name": "base",
+      "title": "base",
+      "environment_name": "production",
+      "puppetclasses": [{"id": 10, "name": "ntp", "module_name": "ntp"}],
+      "parameters": [
+        {"name": "datacenter", "value": "dub1", "parameter_type": "string"},
+        {"name": "dns_servers", "value": "[\"10.0.0.1\", \"10.0.0.2\"]", "parameter_type": "array"}
+      ]
+    },
+    {
+      "id": 2,
+      "name": "web",
+      "title": "base/web",
+      "parent_id": 1,
+      "puppetclasses": {"nginx": [{"id": 11, "name": "nginx"}]},
+      "smart_class_parameters": [
+        {
+          "parameter": "worker_processes",
+          "puppetclass_name": "nginx",
+          "parameter_type": "integer",
+          "override_values": [
+            {"match": "hostgroup=base/web", "value": "8"},
+            {"match": "hostgroup=other", "value": "2"}
+          ]
+        },
+        {"parameter": "ssl", "puppetclass": {"name": "nginx"}, "parameter_type": "boolean", "value": "true"},
+        {"parameter": "missing", "puppetclass_name": "nginx", "override_values": []}
+      ]
+    },
+    {
+      "id": 3,
+      "name": "web",
+      "title": "base/canary/web",
+      "parent_id": 99,
+      "parameters": [{"name": "workers", "value": "lots", "parameter_type": "integer"}]
+    },
+    {"id": 4, "name": "database", "title": "base/database"}
+  ]
+}
+`
+
+var foremanHostData = `
+[
+  {"name": "web-0001.example.com", "hostgroup_id": 2},
+  {"name": "web-0002.example.com", "certname": "web-0002", "hostgroup_title": "base/web", "environment_name": "canary"},
+  {"name": "db-0001.example.com", "hostgroup_name": "database", "parameters": [{"name": "role", "value": "primary"}]},
+  {"name": "orphan.example.com"}
+]
+`
+
+func TestImportForeman(t *testing.T) {
+	assert := assert.New(t)
+	c := newFixtureConfig()
+	staging := c.ENCs["staging"]
+
+	report, err := staging.ImportForeman([]byte(foremanHostgroupData), []byte(foremanHostData))
+	assert.Nil(err)
+
+	assert.Equal([]string{"base", "base_web", "base_canary_web", "database"}, report.Nodegroups)
+	assert.Equal([]string{"web-0001.example.com", "web-0002", "db-0001.example.com"}, report.Nodes)
+	assert.Equal([]string{
+		"Smart class parameter nginx::missing has no value for host group base/web",
+		"Parameter workers of host group base/canary/web kept as a string: strconv.Atoi: parsing \"lots\": invalid syntax",
+		"Parent of host group base/canary/web is not in the export",
+		"Host web-0002 uses environment canary instead of its host group's",
+		"Host db-0001.example.com has its own parameters",
+		"Host orphan.example.com has no imported host group",
+	}, report.Unmapped)
+
+	base := staging.Nodegroups["base"]
+	assert.Equal("", base.Parent)
+	assert.Equal("production", base.Environment)
+	assert.Equal(map[string]interface{}{"ntp": map[string]interface{}{}}, base.Classes)
+	assert.Equal(map[string]interface{}{
+		"datacenter":  "dub1",
+		"dns_servers": []interface{}{"10.0.0.1", "10.0.0.2"},
+	}, base.Parameters)
+
+	web := staging.Nodegroups["base_web"]
+	assert.Equal("base@staging", web.Parent)
+	assert.Equal(map[string]interface{}{
+		"nginx": map[string]interface{}{"worker_processes": 8, "ssl": true},
+	}, web.Classes)
+	assert.Equal([]string{"web-0001.example.com", "web-0002"}, web.Nodes)
+
+	assert.Equal("database@staging", QualifyNodegroup(staging.NodeNodegroups("db-0001.example.com")[0], "staging"))
+	assert.Equal("base@staging", staging.Nodegroups["database"].Parent)
+
+	node, err := staging.GetNode("web-0002")
+	assert.Nil(err)
+	assert.Equal("production", node.Environment)
+	assert.Contains(node.Classes, "ntp")
+}
+
+func TestImportForemanInvalid(t *testing.T) {
+	assert := assert.New(t)
+	c := newFixtureConfig()
+
+	_, err := c.ENCs["staging"].ImportForeman([]byte(`{"results": "nope"}`), []byte{})
+	assert.NotNil(err)
+
+	_, err = c.ENCs["staging"].ImportForeman([]byte(`[]`), []byte(`not json`))
+	assert.NotNil(err)
+}
@@ -0,0 +1,295 @@
+package enc
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/hashicorp/hcl"
+	"gopkg.in/yaml.v2"
+)
+
+// Formats lists every ENC file format, which is also its file extension
+var Formats = []string{"json", "yaml", "toml", "hcl"}
+
+// FormatForFile returns the format of an ENC file from its extension
+func FormatForFile(fileName string) (string, error) {
+	switch extension := strings.ToLower(filepath.Ext(fileName)); extension {
+	case ".json":
+		return "json", nil
+	case ".yaml", ".yml":
+		return "yaml", nil
+	case ".toml":
+		return "toml", nil
+	case ".hcl":
+		return "hcl", nil
+	default:
+		return "", errors.New("Unrecognised file extension, expecting: json|yaml|toml|hcl")
+	}
+}
+
+// DecodeENC parses the contents of an ENC file into raw nodegroups, with the same types whatever
+// the format: maps are map[string]interface{}, lists []interface{} and whole numbers int
+func DecodeENC(format string, data []byte) (map[string]interface{}, error) {
+	var (
+		rawEnc map[string]interface{}
+		err    error
+	)
+
+	switch format {
+	case "json":
+		err = json.Unmarshal(data, &rawEnc)
+	case "yaml":
+		var rawYAML map[string]interface{}
+		if err = yaml.Unmarshal(data, &rawYAML); err == nil {
+			// YAML unmarshalling returns type map[interface{}]interface{} regardless of provided type
+			// so until that's fixed, some conversion has to take place
+			rawEnc = make(map[string]interface{}, len(rawYAML))
+			for k, v := range rawYAML {
+				rawEnc[k] = stringifyYAMLMapKeys(v)
+			}
+		}
+	case "toml":
+		_, err = toml.Decode(string(data), &rawEnc)
+	case "hcl":
+		err = hcl.Decode(&rawEnc, string(data))
+	default:
+		return rawEnc, fmt.Errorf("Unrecognised format, expecting: %s: %s", strings.Join(Formats, "|"), format)
+	}
+
+	if err != nil {
+		return rawEnc, err
+	}
+
+	for k, v := range rawEnc {
+		rawEnc[k] = normaliseDecodedValue(v, format == "hcl")
+	}
+
+	return rawEnc, nil
+}
+
+// EncodeENC serialises nodegroups in one of the Formats
+func EncodeENC(format string, nodegroups map[string]Nodegroup) ([]byte, error) {
+	switch format {
+	case "json":
+		contents, err := json.MarshalIndent(nodegroups, "", "  ")
+		return append(contents, '\n'), err
+	case "yaml":
+		return yaml.Marshal(nodegroups)
+	case "toml":
+		// The TOML encoder can't inline Extra, so those nodegroups are written as plain maps
+		tomlNodegroups := make(map[string]interface{}, len(nodegroups))
+		for name, nodegroup := range nodegroups {
+			if len(nodegroup.Extra) > 0 {
+				tomlNodegroups[name] = nodegroupValues(nodegroup)
+			} else {
+				tomlNodegroups[name] = nodegroup
+			}
+		}
+
+		var buffer bytes.Buffer
+		err := toml.NewEncoder(&buffer).Encode(tomlNodegroups)
+		return buffer.Bytes(), err
+	case "hcl":
+		return encodeHCL(nodegroups)
+	default:
+		return []byte{}, fmt.Errorf("Unrecognised format, expecting: %s: %s", strings.Join(Formats, "|"), format)
+	}
+}
+
+// normaliseDecodedValue turns TOML's arrays of tables and int64s into the types JSON and YAML
+// produce. With mergeBlocks, lists of maps are HCL blocks and merged back into a single map.
+func normaliseDecodedValue(value interface{}, mergeBlocks bool) interface{} {
+	switch val := value.(type) {
+	case []map[string]interface{}:
+		if mergeBlocks {
+			merged := make(map[string]interface{})
+			for _, block := range val {
+				for k, v := range block {
+					merged[k] = normaliseDecodedValue(v, mergeBlocks)
+				}
+			}
+			return merged
+		}
+
+		list := make([]interface{}, 0, len(val))
+		for _, table := range val {
+			list = append(list, normaliseDecodedValue(table, mergeBlocks))
+		}
+		return list
+	case map[string]interface{}:
+		for k, v := range val {
+			val[k] = normaliseDecodedValue(v, mergeBlocks)
+		}
+		return val
+	case []interface{}:
+		for i, v := range val {
+			val[i] = normaliseDecodedValue(v, mergeBlocks)
+		}
+		return val
+	case int64:
+		if val >= math.MinInt32 && val <= math.MaxInt32 {
+			return int(val)
+		}
+		return val
+	default:
+		return value
+	}
+}
+
+var hclIdentifier = regexp.MustCompile("^[A-Za-z_][A-Za-z0-9_-]*$")
+
+// encodeHCL writes nodegroups as HCL blocks, with nested maps as nested blocks
+func encodeHCL(nodegroups map[string]Nodegroup) ([]byte, error) {
+	var buffer bytes.Buffer
+
+	names := make([]string, 0, len(nodegroups))
+	for name := range nodegroups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for i, name := range names {
+		if i > 0 {
+			buffer.WriteString("\n")
+		}
+		if err := writeHCLBlock(&buffer, name, nodegroupValues(nodegroups[name]), ""); err != nil {
+			return []byte{}, fmt.Errorf("Nodegroup %s: %s", name, err)
+		}
+	}
+
+	return buffer.Bytes(), nil
+}
+
+func writeHCLBlock(buffer *bytes.Buffer, name string, attributes map[string]interface{}, indent string) error {
+	if len(attributes) == 0 {
+		fmt.Fprintf(buffer, "%s%s {}\n", indent, hclKey(name))
+		return nil
+	}
+
+	fmt.Fprintf(buffer, "%s%s {\n", indent, hclKey(name))
+
+	keys := make([]string, 0, len(attributes))
+	for key := range attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if nested, ok := attributes[key].(map[string]interface{}); ok {
+			if err := writeHCLBlock(buffer, key, nested, indent+"  "); err != nil {
+				return err
+			}
+			continue
+		}
+
+		value, err := hclValue(attributes[key])
+		if err != nil {
+			return fmt.Errorf("%s: %s", key, err)
+		}
+		fmt.Fprintf(buffer, "%s  %s = %s\n", indent, hclKey(key), value)
+	}
+
+	fmt.Fprintf(buffer, "%s}\n", indent)
+	return nil
+}
+
+// hclValue formats a value inline, maps inside lists becoming { key = value } objects
+func hclValue(value interface{}) (string, error) {
+	switch val := value.(type) {
+	case string:
+		return strconv.Quote(val), nil
+	case bool:
+		return strconv.FormatBool(val), nil
+	case int:
+		return strconv.Itoa(val), nil
+	case int64:
+		return strconv.FormatInt(val, 10), nil
+	case float64:
+		if val == math.Trunc(val) && math.Abs(val) < 1e15 {
+			return strconv.FormatInt(int64(val), 10), nil
+		}
+		return strconv.FormatFloat(val, 'g', -1, 64), nil
+	case []interface{}:
+		items := make([]string, 0, len(val))
+		for _, item := range val {
+			itemValue, err := hclValue(item)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, itemValue)
+		}
+		return "[" + strings.Join(items, ", ") + "]", nil
+	case map[string]interface{}:
+		keys := make([]string, 0, len(val))
+		for key := range val {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		items := make([]string, 0, len(val))
+		for _, key := range keys {
+			itemValue, err := hclValue(val[key])
+			if err != nil {
+				return "", err
+			}
+			items = append(items, hclKey(key)+" = "+itemValue)
+		}
+		return "{" + strings.Join(items, ", ") + "}", nil
+	case nil:
+		return "", errors.New("HCL has no null value")
+	default:
+		return "", fmt.Errorf("Unsupported value for HCL: %v", value)
+	}
+}
+
+func hclKey(key string) string {
+	if hclIdentifier.MatchString(key) {
+		return key
+	}
+
+	return strconv.Quote(key)
+}
+
+// Encode serialises the ENC in one of the Formats, checking that reading the result back gives
+// the same nodegroups so a conversion can't silently lose data
+func (enc *ENC) Encode(format string) ([]byte, error) {
+	contents, err := EncodeENC(format, enc.Nodegroups)
+	if err != nil {
+		return contents, err
+	}
+
+	decoded, err := DecodeENC(format, contents)
+	if err != nil {
+		return contents, fmt.Errorf("Could not read the %s back: %s", format, err)
+	}
+
+	// Compare through JSON, which doesn't care whether a number was decoded as an int or a float
+	original, err := json.Marshal(enc.Nodegroups)
+	if err != nil {
+		return contents, err
+	}
+
+	roundTrip, err := json.Marshal(decoded)
+	if err != nil {
+		return contents, err
+	}
+
+	var originalValue, roundTripValue interface{}
+	json.Unmarshal(original, &originalValue)
+	json.Unmarshal(roundTrip, &roundTripValue)
+	if !reflect.DeepEqual(originalValue, roundTripValue) {
+		return contents, fmt.Errorf("Converting to %s would lose data", format)
+	}
+
+	return contents, nil
+}
@@ -0,0 +1,90 @@
+package enc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatForFile(t *testing.T) {
+	assert := assert.New(t)
+
+	for file, want := range map[string]string{
+		"production.json": "json",
+		"production.yaml": "yaml",
+		"production.YML":  "yaml",
+		"production.toml": "toml",
+		"production.hcl":  "hcl",
+	} {
+		got, err := FormatForFile(file)
+		assert.Nil(err)
+		assert.Equal(want, got)
+	}
+
+	_, err := FormatForFile("production.ini")
+	assert.NotNil(err)
+}
+
+func TestEncodeRoundTrip(t *testing.T) {
+	assert := assert.New(t)
+	c := newFixtureConfig()
+	production := c.ENCs["production"]
+	production.AddParameter("website", "dns_servers", []interface{}{"10.0.0.1", "10.0.0.2"})
+	production.AddParameter("website", "limits", map[string]interface{}{"memory": "2G", "ratio": 1.5, "strict": true, "empty": map[string]interface{}{}})
+	production.AddParameter("website", "backends", []interface{}{map[string]interface{}{"host": "app-0001", "port": 8080}})
+	production.AddParameter("database", "quoted \"key\"", "line\nbreak")
+
+	for _, format := range Formats {
+		contents, err := production.Encode(format)
+		assert.Nil(err, format)
+
+		rawEnc, err := DecodeENC(format, contents)
+		assert.Nil(err, format)
+		assert.EqualValues(8, rawEnc["website"].(map[string]interface{})["classes"].(map[string]interface{})["nginx"].(map[string]interface{})["worker_processes"], format)
+		assert.Equal("canary", rawEnc["website_canary"].(map[string]interface{})["environment"], format)
+		assert.Equal([]interface{}{"web-0001", "web-0002"}, rawEnc["website"].(map[string]interface{})["nodes"], format)
+	}
+}
+
+func TestEncodeLossy(t *testing.T) {
+	assert := assert.New(t)
+	c := newFixtureConfig()
+	c.ENCs["production"].AddParameter("globals", "unset", nil)
+
+	_, err := c.ENCs["production"].Encode("hcl")
+	assert.NotNil(err)
+
+	_, err = c.ENCs["production"].Encode("json")
+	assert.Nil(err)
+
+	_, err = c.ENCs["production"].Encode("ini")
+	assert.NotNil(err)
+}
+
+func TestDecodeHCLBlocks(t *testing.T) {
+	assert := assert.New(t)
+
+	rawEnc, err := DecodeENC("hcl", []byte(`
+website {
+  parent = "globals@production"
+  nodes = ["web-0001"]
+  classes {
+    nginx {
+      worker_processes = 8
+    }
+    ntp {}
+  }
+}
+`))
+	assert.Nil(err)
+	assert.Equal(map[string]interface{}{
+		"website": map[string]interface{}{
+			"parent": "globals@production",
+			"nodes":  []interface{}{"web-0001"},
+			"classes": map[string]interface{}{
+				"nginx": map[string]interface{}{"worker_processes": 8},
+				"ntp":   map[string]interface{}{},
+			},
+		},
+	}, rawEnc)
+}
@@ -3,6 +3,7 @@ package enc
 import (
 	"fmt"
 	"reflect"
+	"sort"
 )
 
 func reverse(s []string) []string {
@@ -59,7 +60,13 @@ func MergeNestedMaps(mapA map[string]interface{}, mapB map[string]interface{}) m
 				if mapA == nil {
 					mapA = make(map[string]interface{})
 				}
-				mapA[key] = val
+				// Copy nested maps rather than sharing them, otherwise later merges would
+				// write into the nodegroup the map was taken from
+				if valMap, ok := val.(map[string]interface{}); ok {
+					mapA[key] = MergeNestedMaps(nil, valMap)
+				} else {
+					mapA[key] = val
+				}
 			}
 		} else {
 			if mapA == nil {
@@ -71,3 +78,53 @@ func MergeNestedMaps(mapA map[string]interface{}, mapB map[string]interface{}) m
 
 	return mapA
 }
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+func containsString(list []string, value string) bool {
+	for _, existing := range list {
+		if existing == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+func appendUnique(list []string, value string) []string {
+	for _, existing := range list {
+		if existing == value {
+			return list
+		}
+	}
+
+	return append(list, value)
+}
+
+// copyValue deep copies maps and lists, so changes to the copy can't reach the original
+func copyValue(value interface{}) interface{} {
+	switch val := value.(type) {
+	case map[string]interface{}:
+		copied := make(map[string]interface{}, len(val))
+		for k, v := range val {
+			copied[k] = copyValue(v)
+		}
+		return copied
+	case []interface{}:
+		copied := make([]interface{}, 0, len(val))
+		for _, v := range val {
+			copied = append(copied, copyValue(v))
+		}
+		return copied
+	default:
+		return value
+	}
+}
@@ -0,0 +1,70 @@
+package enc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newFixtureConfig builds a two-ENC config in memory with a cross-ENC parent:
+//
+//	production: globals -> website -> website_canary, globals -> database
+//	staging:    staging_web -> website@production
+func newFixtureConfig() *Config {
+	c := &Config{ENCs: map[string]*ENC{}}
+
+	for _, name := range []string{"production", "staging"} {
+		fixtureEnc := NewENC("yaml", "/tmp/enc_fixture-"+name+".yaml")
+		fixtureEnc.Name = name
+		fixtureEnc.ConfigLink = c
+		c.ENCs[name] = fixtureEnc
+	}
+
+	production, staging := c.ENCs["production"], c.ENCs["staging"]
+
+	production.AddNodegroup("globals", "", map[string]interface{}{
+		"ntp": map[string]interface{}{"servers": []interface{}{"0.pool.ntp.org", "1.pool.ntp.org"}},
+	}, []string{}, map[string]interface{}{"datacenter": "dub1"})
+	production.SetEnvironment("globals", "production")
+
+	production.AddNodegroup("website", "globals@production", map[string]interface{}{
+		"nginx": map[string]interface{}{"worker_processes": 8},
+	}, []string{}, map[string]interface{}{})
+
+	production.AddNodegroup("website_canary", "website@production", map[string]interface{}{
+		"nginx": map[string]interface{}{"worker_processes": 2},
+	}, []string{}, map[string]interface{}{})
+	production.SetEnvironment("website_canary", "canary")
+
+	production.AddNodegroup("database", "globals@production", map[string]interface{}{
+		"mysql": map[string]interface{}{},
+	}, []string{}, map[string]interface{}{})
+
+	staging.AddNodegroup("staging_web", "website@production", map[string]interface{}{}, []string{}, map[string]interface{}{
+		"datacenter": "dub2",
+	})
+	staging.SetEnvironment("staging_web", "staging")
+
+	production.AddNodes("website", []string{"web-0001", "web-0002"})
+	production.AddNode("website_canary", "web-0001")
+	production.AddNode("database", "db-0001")
+	staging.AddNode("staging_web", "web-0101")
+
+	return c
+}
+
+func TestMergeNestedMapsCopiesValues(t *testing.T) {
+	assert := assert.New(t)
+
+	source := map[string]interface{}{
+		"nginx": map[string]interface{}{"worker_processes": 8},
+	}
+
+	merged := MergeNestedMaps(nil, source)
+	MergeNestedMaps(merged, map[string]interface{}{
+		"nginx": map[string]interface{}{"worker_processes": 2},
+	})
+
+	assert.Equal(map[string]interface{}{"worker_processes": 8}, source["nginx"])
+	assert.Equal(map[string]interface{}{"worker_processes": 2}, merged["nginx"])
+}
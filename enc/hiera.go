@@ -0,0 +1,153 @@
+package enc
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// HieraData is the data of every ENC laid out as Hiera data files, keyed by file name without
+// the .yaml extension
+type HieraData struct {
+	Nodes  map[string]map[string]interface{}
+	Groups map[string]map[string]interface{}
+}
+
+// HieraData resolves the parameters of every node, using conflictRule for nodes present in
+// several ENCs. With groups, it also includes each nodegroup's own parameters so Hiera can do
+// the inheritance itself.
+func (c *Config) HieraData(conflictRule string, groups bool) (*HieraData, error) {
+	data := &HieraData{
+		Nodes:  make(map[string]map[string]interface{}),
+		Groups: make(map[string]map[string]interface{}),
+	}
+	groupSources := make(map[string]string)
+
+	for _, encName := range c.ENCNames() {
+		currentEnc := c.ENCs[encName]
+
+		for _, node := range currentEnc.NodeNames() {
+			if _, ok := data.Nodes[node]; ok {
+				continue
+			}
+
+			lookup, err := c.LookupNode(node, conflictRule)
+			if err != nil {
+				return &HieraData{}, err
+			}
+			data.Nodes[node] = hieraParameters(lookup.Nodegroup.Parameters)
+		}
+
+		if !groups {
+			continue
+		}
+
+		for _, name := range currentEnc.NodegroupNames() {
+			qualified := QualifyNodegroup(name, encName)
+			if source, ok := groupSources[name]; ok {
+				return &HieraData{}, fmt.Errorf("Nodegroups %s and %s both map to the Hiera file groups/%s.yaml", source, qualified, name)
+			}
+			groupSources[name] = qualified
+
+			data.Groups[name] = hieraParameters(currentEnc.Nodegroups[name].Parameters)
+		}
+	}
+
+	return data, nil
+}
+
+// HieraNodegroups lists the nodegroups classifying a node in the given ENCs, most specific first:
+// the nodegroups it's in, deepest first, then their parents, then their grandparents. Classify passes it to Puppet
+// as the "nodegroups" parameter the groups level of HieraConfig maps over.
+func (c *Config) HieraNodegroups(nodeName string, encNames []string) []string {
+	names := make([]string, 0)
+	seen := make(map[string]bool)
+
+	for _, encName := range encNames {
+		currentEnc, ok := c.ENCs[encName]
+		if !ok {
+			continue
+		}
+
+		memberships := currentEnc.NodeMemberships(nodeName)
+		sort.Stable(byPathLength(memberships))
+
+		// Every nodegroup the node is in comes before their parents, then their grandparents
+		for depth := 0; len(memberships) > 0 && depth < len(memberships[0].Path); depth++ {
+			for _, membership := range memberships {
+				if depth >= len(membership.Path) {
+					continue
+				}
+
+				// Group files are named after the nodegroup alone
+				name := strings.Split(membership.Path[len(membership.Path)-1-depth], "@")[0]
+				if !seen[name] {
+					seen[name] = true
+					names = append(names, name)
+				}
+			}
+		}
+	}
+
+	return names
+}
+
+// byPathLength orders memberships deepest first
+type byPathLength []Membership
+
+func (m byPathLength) Len() int           { return len(m) }
+func (m byPathLength) Swap(i, j int)      { m[i], m[j] = m[j], m[i] }
+func (m byPathLength) Less(i, j int) bool { return len(m[i].Path) > len(m[j].Path) }
+
+// HieraConfig returns a hiera.yaml (version 5) snippet looking up node files first, then group
+// files for every name in the top-scope variable "nodegroups" set by classify, most specific first
+func (data *HieraData) HieraConfig(datadir string) []byte {
+	config := "---\n" +
+		"version: 5\n" +
+		"defaults:\n" +
+		fmt.Sprintf("  datadir: %q\n", datadir) +
+		"  data_hash: yaml_data\n" +
+		"hierarchy:\n" +
+		"  - name: \"ENC nodes\"\n" +
+		"    path: \"nodes/%{trusted.certname}.yaml\"\n"
+
+	if len(data.Groups) > 0 {
+		config += "  - name: \"ENC nodegroups\"\n" +
+			"    mapped_paths: [nodegroups, nodegroup, \"groups/%{nodegroup}.yaml\"]\n"
+	}
+
+	return []byte(config)
+}
+
+// WriteOut writes nodes/<certname>.yaml, groups/<nodegroup>.yaml and hiera.yaml into dir
+func (data *HieraData) WriteOut(dir string) error {
+	files := map[string]map[string]map[string]interface{}{"nodes": data.Nodes}
+	if len(data.Groups) > 0 {
+		files["groups"] = data.Groups
+	}
+
+	for subdir, contents := range files {
+		if err := os.MkdirAll(filepath.Join(dir, subdir), 0755); err != nil {
+			return err
+		}
+
+		for name, parameters := range contents {
+			if err := writeYAMLFile(filepath.Join(dir, subdir, name+".yaml"), parameters); err != nil {
+				return err
+			}
+		}
+	}
+
+	return ioutil.WriteFile(filepath.Join(dir, "hiera.yaml"), data.HieraConfig(dir), 0644)
+}
+
+func hieraParameters(parameters map[string]interface{}) map[string]interface{} {
+	if parameters == nil {
+		return map[string]interface{}{}
+	}
+
+	return parameters
+}
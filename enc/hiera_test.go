@@ -0,0 +1,72 @@
+package enc
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+)
+
+func TestHieraData(t *testing.T) {
+	assert := assert.New(t)
+	c := newFixtureConfig()
+
+	data, err := c.HieraData(NodeConflictError, false)
+	assert.Nil(err)
+	assert.Len(data.Nodes, 4)
+	assert.Len(data.Groups, 0)
+	assert.Equal(map[string]interface{}{"datacenter": "dub2"}, data.Nodes["web-0101"])
+	assert.NotContains(string(data.HieraConfig("/etc/puppetlabs/hiera")), "mapped_paths")
+
+	data, err = c.HieraData(NodeConflictError, true)
+	assert.Nil(err)
+	assert.Len(data.Groups, 5)
+	assert.Equal(map[string]interface{}{"datacenter": "dub1"}, data.Groups["globals"])
+	assert.Equal(map[string]interface{}{}, data.Groups["website"])
+
+	var hieraConfig map[string]interface{}
+	assert.Nil(yaml.Unmarshal(data.HieraConfig("/etc/puppetlabs/hiera"), &hieraConfig))
+	assert.Equal(5, hieraConfig["version"])
+	assert.Len(hieraConfig["hierarchy"], 2)
+
+	c.ENCs["staging"].AddNodegroup("website", "", map[string]interface{}{}, []string{}, map[string]interface{}{})
+	_, err = c.HieraData(NodeConflictError, true)
+	assert.NotNil(err)
+}
+
+func TestHieraDataWriteOut(t *testing.T) {
+	assert := assert.New(t)
+	c := newFixtureConfig()
+
+	dir, err := ioutil.TempDir("", "go-enc-hiera")
+	assert.Nil(err)
+	defer os.RemoveAll(dir)
+
+	data, err := c.HieraData(NodeConflictError, true)
+	assert.Nil(err)
+	assert.Nil(data.WriteOut(dir))
+
+	contents, err := ioutil.ReadFile(filepath.Join(dir, "nodes", "db-0001.yaml"))
+	assert.Nil(err)
+	assert.Equal("datacenter: dub1\n", string(contents))
+
+	contents, err = ioutil.ReadFile(filepath.Join(dir, "groups", "website_canary.yaml"))
+	assert.Nil(err)
+	assert.Equal("{}\n", string(contents))
+
+	_, err = os.Stat(filepath.Join(dir, "hiera.yaml"))
+	assert.Nil(err)
+}
+
+func TestHieraNodegroups(t *testing.T) {
+	assert := assert.New(t)
+	c := newFixtureConfig()
+
+	assert.Equal([]string{"website_canary", "website", "globals"}, c.HieraNodegroups("web-0001", []string{"production"}))
+	assert.Equal([]string{"database", "globals"}, c.HieraNodegroups("db-0001", []string{"production"}))
+	assert.Equal([]string{"staging_web", "website", "globals"}, c.HieraNodegroups("web-0101", []string{"staging"}))
+	assert.Equal([]string{}, c.HieraNodegroups("web-0101", []string{"production"}))
+}
@@ -0,0 +1,210 @@
+package enc
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// InterpolationLiteralPrefix at the start of a string value turns interpolation off for that
+// value, and is removed from it
+const InterpolationLiteralPrefix = "%!"
+
+// interpolationPattern matches %{reference}, and %%{reference} which is written out as %{reference}
+var interpolationPattern = regexp.MustCompile(`%?%\{([^{}]*)\}`)
+
+// interpolator resolves the references in one classification. Parameters are looked up
+// uninterpolated and resolved on demand, so a value can reference one that references another.
+type interpolator struct {
+	parameters map[string]interface{}
+	node       string
+	nodegroup  string
+	resolved   map[string]interface{}
+	resolving  []string
+}
+
+// interpolateNodegroup returns a copy of nodegroup with the references in its parameters and class
+// parameters resolved: %{param.<path>}, %{node.name}, %{nodegroup} and %{env.<VAR>}. Without a
+// node, %{node.name} is left as it is, as are references go-enc doesn't recognise, e.g. for Hiera.
+func interpolateNodegroup(nodegroup *Nodegroup, node string, nodegroupName string) (*Nodegroup, error) {
+	in := &interpolator{
+		parameters: nodegroup.Parameters,
+		node:       node,
+		nodegroup:  nodegroupName,
+		resolved:   make(map[string]interface{}),
+	}
+
+	interpolated := *nodegroup
+	if nodegroup.Parameters != nil {
+		interpolated.Parameters = make(map[string]interface{}, len(nodegroup.Parameters))
+		for _, key := range sortedKeys(nodegroup.Parameters) {
+			value, _, err := in.parameter([]pathSegment{{key: key, index: -1}})
+			if err != nil {
+				return &Nodegroup{}, err
+			}
+			interpolated.Parameters[key] = value
+		}
+	}
+
+	if nodegroup.Classes != nil {
+		classes, err := in.value(nodegroup.Classes)
+		if err != nil {
+			return &Nodegroup{}, err
+		}
+		interpolated.Classes = classes.(map[string]interface{})
+	}
+
+	return &interpolated, nil
+}
+
+func (in *interpolator) value(value interface{}) (interface{}, error) {
+	switch val := value.(type) {
+	case string:
+		return in.str(val)
+	case map[string]interface{}:
+		interpolated := make(map[string]interface{}, len(val))
+		for _, key := range sortedKeys(val) {
+			itemValue, err := in.value(val[key])
+			if err != nil {
+				return nil, err
+			}
+			interpolated[key] = itemValue
+		}
+		return interpolated, nil
+	case []interface{}:
+		interpolated := make([]interface{}, 0, len(val))
+		for _, item := range val {
+			itemValue, err := in.value(item)
+			if err != nil {
+				return nil, err
+			}
+			interpolated = append(interpolated, itemValue)
+		}
+		return interpolated, nil
+	default:
+		return value, nil
+	}
+}
+
+func (in *interpolator) str(value string) (interface{}, error) {
+	if strings.HasPrefix(value, InterpolationLiteralPrefix) {
+		return strings.TrimPrefix(value, InterpolationLiteralPrefix), nil
+	}
+
+	matches := interpolationPattern.FindAllStringSubmatchIndex(value, -1)
+	if len(matches) == 0 {
+		return value, nil
+	}
+
+	// A value that's nothing but a reference takes the type of what it references, e.g. a list
+	if len(matches) == 1 && matches[0][0] == 0 && matches[0][1] == len(value) && value[1] != '%' {
+		return in.lookup(value[matches[0][2]:matches[0][3]])
+	}
+
+	var buffer bytes.Buffer
+	last := 0
+	for _, match := range matches {
+		buffer.WriteString(value[last:match[0]])
+		last = match[1]
+
+		if value[match[0]+1] == '%' {
+			buffer.WriteString(value[match[0]+1 : match[1]])
+			continue
+		}
+
+		reference := value[match[2]:match[3]]
+		resolved, err := in.lookup(reference)
+		if err != nil {
+			return nil, err
+		}
+
+		switch val := resolved.(type) {
+		case map[string]interface{}, []interface{}:
+			return nil, fmt.Errorf("Can't interpolate %s into a string: %%{%s}", pathTypeName(val), reference)
+		case nil:
+			// null reads as an empty string
+		default:
+			buffer.WriteString(fmt.Sprint(val))
+		}
+	}
+	buffer.WriteString(value[last:])
+
+	return buffer.String(), nil
+}
+
+// lookup resolves a reference, returning it as it was written when it's left alone
+func (in *interpolator) lookup(reference string) (interface{}, error) {
+	reference = strings.TrimSpace(reference)
+	unresolved := "%{" + reference + "}"
+
+	switch {
+	case reference == "nodegroup":
+		if in.nodegroup == "" {
+			return unresolved, nil
+		}
+		return in.nodegroup, nil
+	case reference == "node.name":
+		if in.node == "" {
+			return unresolved, nil
+		}
+		return in.node, nil
+	case strings.HasPrefix(reference, "env."):
+		name := strings.TrimPrefix(reference, "env.")
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return nil, fmt.Errorf("Environment variable is not set: %s", unresolved)
+		}
+		return value, nil
+	case strings.HasPrefix(reference, "param."):
+		segments, err := parsePath(strings.TrimPrefix(reference, "param."))
+		if err != nil {
+			return nil, fmt.Errorf("Can't interpolate %s: %s", unresolved, err)
+		}
+
+		value, found, err := in.parameter(segments)
+		if err == nil && !found {
+			err = fmt.Errorf("Can't interpolate %s: %s does not exist", unresolved, formatPath(segments))
+		}
+		return value, err
+	default:
+		return unresolved, nil
+	}
+}
+
+// parameter resolves the parameter at segments, found being false if there's nothing there
+func (in *interpolator) parameter(segments []pathSegment) (interface{}, bool, error) {
+	name := formatPath(segments)
+	if value, ok := in.resolved[name]; ok {
+		return value, true, nil
+	}
+
+	for i, resolving := range in.resolving {
+		if resolving == name {
+			cycle := make([]string, 0)
+			for _, step := range append(in.resolving[i:len(in.resolving):len(in.resolving)], name) {
+				cycle = append(cycle, "param."+step)
+			}
+			return nil, false, fmt.Errorf("Interpolation cycle: %s", strings.Join(cycle, " -> "))
+		}
+	}
+
+	raw, found, err := getPathValue(in.parameters, segments)
+	if err != nil {
+		return nil, false, fmt.Errorf("Can't interpolate %%{param.%s}: %s", name, err)
+	}
+	if !found {
+		return nil, false, nil
+	}
+
+	in.resolving = append(in.resolving, name)
+	value, err := in.value(raw)
+	in.resolving = in.resolving[:len(in.resolving)-1]
+	if err != nil {
+		return nil, false, err
+	}
+
+	in.resolved[name] = value
+	return value, true, nil
+}
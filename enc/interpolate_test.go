@@ -0,0 +1,107 @@
+package enc
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInterpolateNodegroup(t *testing.T) {
+	assert := assert.New(t)
+	os.Setenv("GO_ENC_TEST_REGION", "eu-west-1")
+	defer os.Unsetenv("GO_ENC_TEST_REGION")
+
+	nodegroup := &Nodegroup{
+		Parent: "globals@production",
+		Parameters: map[string]interface{}{
+			"datacenter":  "dub1",
+			"dns":         []interface{}{"10.0.0.2", "10.0.0.3"},
+			"resolvers":   "%{param.dns}",
+			"fqdn_suffix": "%{param.site}.example.com",
+			"site":        "%{ param.datacenter }-%{env.GO_ENC_TEST_REGION}",
+			"motd":        "%{node.name} in %{nodegroup}, %%{not.this}",
+			"workers":     4,
+			"worker_note": "%{param.workers} workers",
+			"template":    "%!%{param.datacenter} stays",
+			"hiera":       "%{facts.hostname}",
+		},
+		Classes: map[string]interface{}{
+			"nginx": map[string]interface{}{
+				"server_name": "web.%{param.fqdn_suffix}",
+				"upstreams":   []interface{}{map[string]interface{}{"host": "app.%{param.datacenter}"}},
+			},
+			"ntp": nil,
+		},
+	}
+
+	got, err := interpolateNodegroup(nodegroup, "web-0001", "website")
+	assert.Nil(err)
+	assert.Equal(map[string]interface{}{
+		"datacenter":  "dub1",
+		"dns":         []interface{}{"10.0.0.2", "10.0.0.3"},
+		"resolvers":   []interface{}{"10.0.0.2", "10.0.0.3"},
+		"fqdn_suffix": "dub1-eu-west-1.example.com",
+		"site":        "dub1-eu-west-1",
+		"motd":        "web-0001 in website, %{not.this}",
+		"workers":     4,
+		"worker_note": "4 workers",
+		"template":    "%{param.datacenter} stays",
+		"hiera":       "%{facts.hostname}",
+	}, got.Parameters)
+	assert.Equal(map[string]interface{}{
+		"nginx": map[string]interface{}{
+			"server_name": "web.dub1-eu-west-1.example.com",
+			"upstreams":   []interface{}{map[string]interface{}{"host": "app.dub1"}},
+		},
+		"ntp": nil,
+	}, got.Classes)
+	assert.Equal("globals@production", got.Parent)
+
+	// The original is left alone
+	assert.Equal("%{param.dns}", nodegroup.Parameters["resolvers"])
+
+	got, err = interpolateNodegroup(&Nodegroup{Parameters: map[string]interface{}{"motd": "%{node.name}"}}, "", "")
+	assert.Nil(err)
+	assert.Equal("%{node.name}", got.Parameters["motd"])
+}
+
+func TestInterpolateErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := map[string]map[string]interface{}{
+		"Interpolation cycle: param.a -> param.b -> param.a":               {"a": "x%{param.b}", "b": "%{param.a}"},
+		"Interpolation cycle: param.a -> param.a":                          {"a": "%{param.a}"},
+		"Can't interpolate %{param.missing}: missing does not exist":       {"a": "%{param.missing}"},
+		"Can't interpolate a list into a string: %{param.list}":            {"a": "x%{param.list}", "list": []interface{}{}},
+		"Can't interpolate %{param.dns.primary}: dns is a list, not a map": {"a": "%{param.dns.primary}", "dns": []interface{}{}},
+		"Environment variable is not set: %{env.GO_ENC_TEST_UNSET}":        {"a": "%{env.GO_ENC_TEST_UNSET}"},
+	}
+
+	for want, parameters := range tests {
+		_, err := interpolateNodegroup(&Nodegroup{Parameters: parameters}, "web-0001", "website")
+		assert.EqualError(err, want)
+	}
+}
+
+func TestGetNodeInterpolates(t *testing.T) {
+	assert := assert.New(t)
+	c := newFixtureConfig()
+	production := c.ENCs["production"]
+
+	_, err := production.AddParameter("globals", "datacenter", "dub1")
+	assert.Nil(err)
+	_, err = production.AddParameter("website", "server_name", "%{node.name}.%{param.datacenter}.example.com")
+	assert.Nil(err)
+
+	node, err := production.GetNode("web-0002")
+	assert.Nil(err)
+	assert.Equal("web-0002.dub1.example.com", node.Parameters["server_name"])
+
+	nodegroup, err := production.GetInheritedNodegroup("website")
+	assert.Nil(err)
+	assert.Equal("%{node.name}.dub1.example.com", nodegroup.Parameters["server_name"])
+
+	// Stored values keep their references
+	assert.Equal("%{node.name}.%{param.datacenter}.example.com", production.Nodegroups["website"].Parameters["server_name"])
+}
@@ -0,0 +1,91 @@
+package enc
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// NodeConflictError fails a lookup when the node is in more than one ENC
+	NodeConflictError = "error"
+	// NodeConflictFirst answers from the ENC with the highest precedence
+	NodeConflictFirst = "first"
+	// NodeConflictMerge merges the classification from every ENC, higher precedence winning
+	NodeConflictMerge = "merge"
+)
+
+// NodeLookup is the classification of a node found by searching every ENC
+type NodeLookup struct {
+	Node      string     `json:"node" yaml:"node"`
+	ENCs      []string   `json:"encs" yaml:"encs"`
+	Nodegroup *Nodegroup `json:"classification" yaml:"classification"`
+}
+
+// PrecedenceOrder returns every ENC name, those listed in Config.Precedence first and in that
+// order, followed by the rest alphabetically
+func (c *Config) PrecedenceOrder() []string {
+	names := make([]string, 0, len(c.ENCs))
+	listed := make(map[string]bool)
+
+	for _, name := range c.Precedence {
+		if _, ok := c.ENCs[name]; ok && !listed[name] {
+			listed[name] = true
+			names = append(names, name)
+		}
+	}
+
+	for _, name := range c.ENCNames() {
+		if !listed[name] {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+// FindNode returns the names of the ENCs that track a node, in precedence order
+func (c *Config) FindNode(nodeName string) []string {
+	names := make([]string, 0)
+	for _, name := range c.PrecedenceOrder() {
+		if _, ok := c.ENCs[name].Nodes.Find(nodeName); ok {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+// LookupNode classifies a node without knowing which ENC it's in. conflictRule decides what
+// happens when more than one ENC has the node: NodeConflictError, NodeConflictFirst or
+// NodeConflictMerge.
+func (c *Config) LookupNode(nodeName string, conflictRule string) (*NodeLookup, error) {
+	encNames := c.FindNode(nodeName)
+	if len(encNames) == 0 {
+		return &NodeLookup{}, fmt.Errorf("Could not find node in any ENC: %s", nodeName)
+	}
+
+	switch conflictRule {
+	case NodeConflictError:
+		if len(encNames) > 1 {
+			return &NodeLookup{}, fmt.Errorf("Node %s is in more than one ENC: %s", nodeName, strings.Join(encNames, ", "))
+		}
+	case NodeConflictFirst:
+		encNames = encNames[:1]
+	case NodeConflictMerge:
+	default:
+		return &NodeLookup{}, fmt.Errorf("Unrecognised conflict rule, expecting: %s|%s|%s", NodeConflictError, NodeConflictFirst, NodeConflictMerge)
+	}
+
+	// Merge from the lowest precedence up so the highest precedence ENC has the final say
+	merged := &Nodegroup{}
+	for i := len(encNames) - 1; i >= 0; i-- {
+		currentEnc := c.ENCs[encNames[i]]
+		node, err := currentEnc.GetNode(nodeName)
+		if err != nil {
+			return &NodeLookup{}, fmt.Errorf("Could not classify node %s in %s: %s", nodeName, encNames[i], err)
+		}
+		merged = currentEnc.mergeNodegroups(merged, node)
+	}
+
+	return &NodeLookup{Node: nodeName, ENCs: encNames, Nodegroup: merged}, nil
+}
@@ -0,0 +1,57 @@
+package enc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrecedenceOrder(t *testing.T) {
+	assert := assert.New(t)
+	c := newFixtureConfig()
+
+	assert.Equal([]string{"production", "staging"}, c.PrecedenceOrder())
+
+	c.Precedence = []string{"staging", "missing", "staging"}
+	assert.Equal([]string{"staging", "production"}, c.PrecedenceOrder())
+}
+
+func TestLookupNode(t *testing.T) {
+	assert := assert.New(t)
+	c := newFixtureConfig()
+
+	lookup, err := c.LookupNode("web-0101", NodeConflictError)
+	assert.Nil(err)
+	assert.Equal([]string{"staging"}, lookup.ENCs)
+	assert.Equal("dub2", lookup.Nodegroup.Parameters["datacenter"])
+	assert.Equal("staging", lookup.Nodegroup.Environment)
+
+	_, err = c.LookupNode("web-9999", NodeConflictError)
+	assert.NotNil(err)
+
+	_, err = c.LookupNode("web-0101", "unknown")
+	assert.NotNil(err)
+}
+
+func TestLookupNodeConflicts(t *testing.T) {
+	assert := assert.New(t)
+	c := newFixtureConfig()
+	c.ENCs["staging"].AddNode("staging_web", "db-0001")
+	c.Precedence = []string{"staging"}
+
+	_, err := c.LookupNode("db-0001", NodeConflictError)
+	assert.NotNil(err)
+
+	lookup, err := c.LookupNode("db-0001", NodeConflictFirst)
+	assert.Nil(err)
+	assert.Equal([]string{"staging"}, lookup.ENCs)
+	assert.NotContains(lookup.Nodegroup.Classes, "mysql")
+
+	lookup, err = c.LookupNode("db-0001", NodeConflictMerge)
+	assert.Nil(err)
+	assert.Equal([]string{"staging", "production"}, lookup.ENCs)
+	assert.Contains(lookup.Nodegroup.Classes, "mysql")
+	assert.Contains(lookup.Nodegroup.Classes, "nginx")
+	assert.Equal("dub2", lookup.Nodegroup.Parameters["datacenter"])
+	assert.Equal("staging", lookup.Nodegroup.Environment)
+}
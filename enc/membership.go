@@ -0,0 +1,107 @@
+package enc
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// MembershipLiteral means the node is listed in the nodegroup's nodes
+	MembershipLiteral = "literal"
+	// MembershipChain means the node is tracked against the nodegroup in the ENC's node chains
+	// without being listed in its nodes
+	MembershipChain = "chain"
+)
+
+// Membership explains why a node is classified by a nodegroup
+type Membership struct {
+	ENC       string   `json:"enc" yaml:"enc"`
+	Nodegroup string   `json:"nodegroup" yaml:"nodegroup"`
+	Source    string   `json:"source" yaml:"source"`
+	Path      []string `json:"path" yaml:"path"`
+}
+
+// NodeMemberships returns the nodegroups that directly contain a node in any ENC of the config
+func (c *Config) NodeMemberships(nodeName string) ([]Membership, error) {
+	memberships := make([]Membership, 0)
+	for _, encName := range c.ENCNames() {
+		memberships = append(memberships, c.ENCs[encName].NodeMemberships(nodeName)...)
+	}
+
+	if len(memberships) == 0 {
+		return memberships, fmt.Errorf("Could not find node in any ENC: %s", nodeName)
+	}
+
+	return memberships, nil
+}
+
+// NodeMemberships returns the nodegroups of this ENC that directly contain a node, each with
+// its ancestor path from the top-most parent down to the nodegroup itself
+func (enc *ENC) NodeMemberships(nodeName string) []Membership {
+	memberships := make([]Membership, 0)
+	seen := make(map[string]bool)
+
+	for _, nodegroup := range enc.NodeNodegroups(nodeName) {
+		qualified := QualifyNodegroup(nodegroup, enc.Name)
+		seen[qualified] = true
+		memberships = append(memberships, Membership{
+			ENC:       enc.Name,
+			Nodegroup: qualified,
+			Source:    MembershipLiteral,
+			Path:      enc.QualifiedParentChain(qualified),
+		})
+	}
+
+	chains, err := enc.GetChains(nodeName)
+	if err != nil {
+		return memberships
+	}
+
+	for _, chain := range chains {
+		pieces := strings.Split(chain, CHAIN_SEPARATION_CHARACTER)
+		if pieces[0] != nodeName || len(pieces) < 2 {
+			// Prefix search also finds longer node names, e.g. node-00010 for node-0001
+			continue
+		}
+
+		qualified := QualifyNodegroup(pieces[len(pieces)-1], enc.Name)
+		if seen[qualified] {
+			continue
+		}
+		seen[qualified] = true
+
+		memberships = append(memberships, Membership{
+			ENC:       enc.Name,
+			Nodegroup: qualified,
+			Source:    MembershipChain,
+			Path:      enc.QualifiedParentChain(qualified),
+		})
+	}
+
+	return memberships
+}
+
+// QualifiedParentChain returns the ancestors of a nodegroup from the top-most parent down to the
+// nodegroup itself, every name qualified with the ENC it belongs to
+func (enc *ENC) QualifiedParentChain(nodegroupName string) []string {
+	current := QualifyNodegroup(nodegroupName, enc.Name)
+	chain := []string{current}
+	visited := map[string]bool{current: true}
+
+	for {
+		nodegroup, err := enc.GetNodegroup(current)
+		if err != nil || nodegroup.Parent == "" {
+			break
+		}
+
+		// Unqualified parents belong to the same ENC as the child that names them
+		current = QualifyNodegroup(nodegroup.Parent, strings.SplitN(current, "@", 2)[1])
+		if visited[current] {
+			break
+		}
+		visited[current] = true
+		chain = append(chain, current)
+	}
+
+	return reverse(chain)
+}
@@ -0,0 +1,75 @@
+package enc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNodeMemberships(t *testing.T) {
+	assert := assert.New(t)
+	c := newFixtureConfig()
+	c.ENCs["staging"].AddNode("staging_web", "web-0001")
+
+	want := []Membership{
+		{
+			ENC:       "production",
+			Nodegroup: "website@production",
+			Source:    MembershipLiteral,
+			Path:      []string{"globals@production", "website@production"},
+		},
+		{
+			ENC:       "production",
+			Nodegroup: "website_canary@production",
+			Source:    MembershipLiteral,
+			Path:      []string{"globals@production", "website@production", "website_canary@production"},
+		},
+		{
+			ENC:       "staging",
+			Nodegroup: "staging_web@staging",
+			Source:    MembershipLiteral,
+			Path:      []string{"globals@production", "website@production", "staging_web@staging"},
+		},
+	}
+
+	got, err := c.NodeMemberships("web-0001")
+	assert.Nil(err)
+	assert.Equal(want, got)
+
+	_, err = c.NodeMemberships("web-9999")
+	assert.NotNil(err)
+}
+
+func TestNodeMembershipsFromChain(t *testing.T) {
+	assert := assert.New(t)
+	c := newFixtureConfig()
+	production := c.ENCs["production"]
+
+	// Tracked in the node chains without being listed in the nodegroup
+	production.Nodes.Add("web-0003", "web-0003")
+	production.Nodes.Add("web-0003$$globals@production$$database@production", "")
+
+	want := []Membership{
+		{
+			ENC:       "production",
+			Nodegroup: "database@production",
+			Source:    MembershipChain,
+			Path:      []string{"globals@production", "database@production"},
+		},
+	}
+
+	assert.Equal(want, production.NodeMemberships("web-0003"))
+}
+
+func TestQualifiedParentChain(t *testing.T) {
+	assert := assert.New(t)
+	c := newFixtureConfig()
+	c.ENCs["production"].AddNodegroup("unqualified", "website", map[string]interface{}{}, []string{}, map[string]interface{}{})
+
+	assert.Equal(
+		[]string{"globals@production", "website@production", "unqualified@production"},
+		c.ENCs["production"].QualifiedParentChain("unqualified"))
+	assert.Equal(
+		[]string{"globals@production", "website@production", "staging_web@staging"},
+		c.ENCs["production"].QualifiedParentChain("staging_web@staging"))
+}
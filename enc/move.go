@@ -0,0 +1,102 @@
+package enc
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/derekparker/trie"
+)
+
+// NodeMove is what moving a node between nodegroups changed. Changes compares the node's
+// classification before and after, by the ENC it's moved from while it's still in a nodegroup of
+// it, and otherwise by the ENC it's moved to.
+type NodeMove struct {
+	Node    string                 `json:"node" yaml:"node"`
+	From    string                 `json:"from" yaml:"from"`
+	To      string                 `json:"to" yaml:"to"`
+	Changes []ClassificationChange `json:"changes" yaml:"changes"`
+}
+
+// MoveNode moves a node between nodegroups given as name@cluster, which can be in different ENCs
+func (c *Config) MoveNode(nodeName string, from string, to string) (*NodeMove, error) {
+	if !strings.Contains(from, "@") {
+		return &NodeMove{}, fmt.Errorf("Nodegroup should be given as name@cluster: %s", from)
+	}
+
+	fromEnc, err := c.GetENC(strings.SplitN(from, "@", 2)[1])
+	if err != nil {
+		return &NodeMove{}, err
+	}
+
+	return fromEnc.MoveNode(nodeName, from, to)
+}
+
+// MoveNode moves a node from a nodegroup of the ENC to another, which can be in another ENC when
+// given as name@cluster. Both nodegroups and the node chains are only changed once the move is
+// known to work.
+func (enc *ENC) MoveNode(nodeName string, from string, to string) (*NodeMove, error) {
+	fromName := strings.TrimSuffix(from, "@"+enc.Name)
+	if strings.Contains(fromName, "@") {
+		return &NodeMove{}, fmt.Errorf("Nodegroup %s is not in ENC %s", from, enc.Name)
+	}
+	fromNodegroup, ok := enc.Nodegroups[fromName]
+	if !ok {
+		return &NodeMove{}, fmt.Errorf("Nodegroup does not exist: %s", from)
+	}
+	if !containsString(fromNodegroup.Nodes, nodeName) {
+		return &NodeMove{}, fmt.Errorf("Node %s is not in nodegroup %s", nodeName, from)
+	}
+
+	toEnc, toName := enc, to
+	if strings.Contains(to, "@") {
+		split := strings.SplitN(to, "@", 2)
+		toName = split[0]
+		if split[1] != enc.Name {
+			if enc.ConfigLink == nil {
+				return &NodeMove{}, fmt.Errorf("ENC does not exist: %s", split[1])
+			}
+			var err error
+			if toEnc, err = enc.ConfigLink.GetENC(split[1]); err != nil {
+				return &NodeMove{}, err
+			}
+		}
+	}
+	toNodegroup, ok := toEnc.Nodegroups[toName]
+	if !ok {
+		return &NodeMove{}, fmt.Errorf("Nodegroup does not exist: %s", to)
+	}
+	if containsString(toNodegroup.Nodes, nodeName) {
+		return &NodeMove{}, fmt.Errorf("Node %s is already in nodegroup %s", nodeName, QualifyNodegroup(toName, toEnc.Name))
+	}
+
+	encs := []*ENC{enc, toEnc}
+	before := classificationIn(encs, nodeName)
+
+	fromNodegroup.Nodes = removeByValueSS(fromNodegroup.Nodes, nodeName)
+	enc.Nodegroups[fromName] = fromNodegroup
+	toNodegroup.Nodes = append(toNodegroup.Nodes, nodeName)
+	toEnc.Nodegroups[toName] = toNodegroup
+
+	enc.rebuildNodeChains()
+	if toEnc != enc {
+		toEnc.rebuildNodeChains()
+	}
+
+	return &NodeMove{
+		Node:    nodeName,
+		From:    QualifyNodegroup(fromName, enc.Name),
+		To:      QualifyNodegroup(toName, toEnc.Name),
+		Changes: diffClassifications(before, classificationIn(encs, nodeName)),
+	}, nil
+}
+
+// rebuildNodeChains rebuilds the chains of every node of the ENC from the nodegroups listing them,
+// for when nodes move or nodegroups change name or parent
+func (enc *ENC) rebuildNodeChains() {
+	enc.Nodes = trie.New()
+	for _, name := range enc.NodegroupNames() {
+		for _, node := range enc.Nodegroups[name].Nodes {
+			enc.addNodeChain(name, node)
+		}
+	}
+}
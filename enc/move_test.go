@@ -0,0 +1,77 @@
+package enc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMoveNodeWithinENC(t *testing.T) {
+	assert := assert.New(t)
+	c := newFixtureConfig()
+	production := c.ENCs["production"]
+
+	move, err := production.MoveNode("web-0002", "website", "database@production")
+	assert.Nil(err)
+	assert.Equal(&NodeMove{
+		Node: "web-0002",
+		From: "website@production",
+		To:   "database@production",
+		Changes: []ClassificationChange{
+			{Node: "web-0002", Field: "class.mysql", Change: "added"},
+			{Node: "web-0002", Field: "class.nginx", Change: "removed"},
+			{Node: "web-0002", Field: "class.nginx.worker_processes", Change: "removed", Before: "8"},
+		},
+	}, move)
+
+	assert.Equal([]string{"web-0001"}, production.Nodegroups["website"].Nodes)
+	assert.Equal([]string{"db-0001", "web-0002"}, production.Nodegroups["database"].Nodes)
+	chains, _ := production.GetChains("web-0002")
+	assert.Equal([]string{"web-0002$$globals@production$$database@production"}, chains)
+}
+
+func TestMoveNodeAcrossENCs(t *testing.T) {
+	assert := assert.New(t)
+	c := newFixtureConfig()
+
+	move, err := c.MoveNode("db-0001", "database@production", "staging_web@staging")
+	assert.Nil(err)
+	assert.Equal([]ClassificationChange{
+		{Node: "db-0001", Field: "class.mysql", Change: "removed"},
+		{Node: "db-0001", Field: "class.nginx", Change: "added"},
+		{Node: "db-0001", Field: "class.nginx.worker_processes", Change: "added", After: "8"},
+		{Node: "db-0001", Field: "environment", Change: "changed", Before: "production", After: "staging"},
+		{Node: "db-0001", Field: "param.datacenter", Change: "changed", Before: "dub1", After: "dub2"},
+	}, move.Changes)
+
+	assert.Equal([]string{"staging"}, c.FindNode("db-0001"))
+	assert.NotContains(c.ENCs["production"].NodeNames(), "db-0001")
+	assert.Equal([]string{"web-0101", "db-0001"}, c.ENCs["staging"].Nodegroups["staging_web"].Nodes)
+
+	// web-0001 stays in website_canary, so production still classifies it the same way
+	move, err = c.MoveNode("web-0001", "website@production", "staging_web@staging")
+	assert.Nil(err)
+	assert.Empty(move.Changes)
+	assert.Equal([]string{"production", "staging"}, c.FindNode("web-0001"))
+}
+
+func TestMoveNodeErrors(t *testing.T) {
+	assert := assert.New(t)
+	c := newFixtureConfig()
+	production := c.ENCs["production"]
+
+	_, err := c.MoveNode("web-0001", "website", "database@production")
+	assert.EqualError(err, "Nodegroup should be given as name@cluster: website")
+	_, err = production.MoveNode("web-0101", "staging_web@staging", "website")
+	assert.EqualError(err, "Nodegroup staging_web@staging is not in ENC production")
+	_, err = production.MoveNode("db-0001", "website", "database")
+	assert.EqualError(err, "Node db-0001 is not in nodegroup website")
+	_, err = production.MoveNode("web-0001", "website", "website_canary")
+	assert.EqualError(err, "Node web-0001 is already in nodegroup website_canary@production")
+	_, err = production.MoveNode("web-0001", "website", "website@testing")
+	assert.EqualError(err, "ENC does not exist: testing")
+
+	// Nothing changed along the way
+	assert.Equal([]string{"web-0001", "web-0002"}, production.Nodegroups["website"].Nodes)
+	assert.Equal([]string{"db-0001"}, production.Nodegroups["database"].Nodes)
+}
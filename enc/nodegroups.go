@@ -0,0 +1,266 @@
+package enc
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// NodegroupMerge is what merging a nodegroup into another changed
+type NodegroupMerge struct {
+	Nodegroup string                 `json:"nodegroup" yaml:"nodegroup"`
+	Into      string                 `json:"into" yaml:"into"`
+	Children  []string               `json:"children" yaml:"children"`
+	Changes   []ClassificationChange `json:"changes" yaml:"changes"`
+}
+
+// RenameNodegroup renames a nodegroup, pointing the parent of its children in every ENC at the new
+// name. It returns the children that were updated.
+func (enc *ENC) RenameNodegroup(oldName string, newName string) ([]string, error) {
+	nodegroup, ok := enc.Nodegroups[oldName]
+	if !ok {
+		return []string{}, fmt.Errorf("Nodegroup does not exist: %s", oldName)
+	}
+	if err := enc.checkNewNodegroupName(newName); err != nil {
+		return []string{}, err
+	}
+
+	delete(enc.Nodegroups, oldName)
+	enc.Nodegroups[newName] = nodegroup
+	if enc.ConfigLink != nil {
+		if doc, ok := enc.ConfigLink.yamlDocuments[enc.FileName]; ok {
+			doc.renameNodegroup(oldName, newName)
+		}
+	}
+
+	children := enc.reparentChildren(oldName, newName)
+	enc.rebuildAllNodeChains()
+	return children, nil
+}
+
+// CopyNodegroup adds a nodegroup with the same parent, classes, parameters and environment as
+// another, and its nodes too when withNodes is set. Children aren't copied.
+func (enc *ENC) CopyNodegroup(name string, newName string, withNodes bool) (*Nodegroup, error) {
+	nodegroup, ok := enc.Nodegroups[name]
+	if !ok {
+		return &Nodegroup{}, fmt.Errorf("Nodegroup does not exist: %s", name)
+	}
+	if err := enc.checkNewNodegroupName(newName); err != nil {
+		return &Nodegroup{}, err
+	}
+
+	copied := Nodegroup{
+		Parent:      nodegroup.Parent,
+		Nodes:       []string{},
+		Environment: nodegroup.Environment,
+	}
+	if nodegroup.Classes != nil {
+		copied.Classes = copyValue(nodegroup.Classes).(map[string]interface{})
+	}
+	if nodegroup.Parameters != nil {
+		copied.Parameters = copyValue(nodegroup.Parameters).(map[string]interface{})
+	}
+	if nodegroup.Extra != nil {
+		copied.Extra = copyValue(nodegroup.Extra).(map[string]interface{})
+	}
+	enc.Nodegroups[newName] = copied
+
+	if withNodes {
+		return enc.AddNodes(newName, nodegroup.Nodes)
+	}
+
+	return &copied, nil
+}
+
+// MergeNodegroup merges a nodegroup into another of the ENC with the same parent and removes it.
+// The other nodegroup gains its classes, parameters, environment and nodes, and becomes the parent
+// of its children in every ENC. Values both nodegroups set differently, or environments they
+// inherit differently, are refused rather than one silently winning. Changes lists how the
+// classification of nodes changed in every ENC.
+func (enc *ENC) MergeNodegroup(name string, into string) (*NodegroupMerge, error) {
+	if name == into {
+		return &NodegroupMerge{}, errors.New("Can't merge a nodegroup into itself")
+	}
+
+	nodegroup, ok := enc.Nodegroups[name]
+	if !ok {
+		return &NodegroupMerge{}, fmt.Errorf("Nodegroup does not exist: %s", name)
+	}
+	target, ok := enc.Nodegroups[into]
+	if !ok {
+		return &NodegroupMerge{}, fmt.Errorf("Nodegroup does not exist: %s", into)
+	}
+
+	if containsString(enc.QualifiedParentChain(into), QualifyNodegroup(name, enc.Name)) {
+		return &NodegroupMerge{}, fmt.Errorf("Can't merge %s into %s, which inherits from it", name, into)
+	}
+
+	// The nodes of both would lose what they inherit from the parent they no longer have
+	if fromParent, intoParent := enc.qualifiedParent(nodegroup), enc.qualifiedParent(target); fromParent != intoParent {
+		return &NodegroupMerge{}, fmt.Errorf("Can't merge %s into %s, they have different parents: %q and %q", name, into, fromParent, intoParent)
+	}
+
+	conflicts, err := nodegroupConflicts(&nodegroup, &target)
+	if err != nil {
+		return &NodegroupMerge{}, err
+	}
+	fromInherited, err := enc.GetInheritedNodegroup(name)
+	if err != nil {
+		return &NodegroupMerge{}, err
+	}
+	intoInherited, err := enc.GetInheritedNodegroup(into)
+	if err != nil {
+		return &NodegroupMerge{}, err
+	}
+	if fromInherited.Environment != intoInherited.Environment && !containsString(conflicts, "environment") {
+		conflicts = append(conflicts, "environment")
+		sort.Strings(conflicts)
+	}
+	if len(conflicts) > 0 {
+		return &NodegroupMerge{}, fmt.Errorf("Nodegroups %s and %s set different values for: %s", name, into, strings.Join(conflicts, ", "))
+	}
+
+	// Nodes of other ENCs can inherit from either nodegroup too
+	before := enc.snapshotLinkedClassifications()
+
+	merged := make(map[string]interface{}, len(nodegroup.Classes))
+	for class, body := range nodegroup.Classes {
+		bodyMap, _ := body.(map[string]interface{})
+		targetMap, _ := target.Classes[class].(map[string]interface{})
+		mergedBody := MergeNestedMaps(copyValue(bodyMap).(map[string]interface{}), targetMap)
+		if err := enc.checkClassSchema(class, mergedBody); err != nil {
+			return &NodegroupMerge{}, err
+		}
+		merged[class] = mergedBody
+	}
+
+	// Only change anything once every class is known to be valid
+	for class, body := range merged {
+		if target.Classes == nil {
+			target.Classes = make(map[string]interface{})
+		}
+		target.Classes[class] = body
+	}
+	if len(nodegroup.Parameters) > 0 {
+		target.Parameters = MergeNestedMaps(copyValue(nodegroup.Parameters).(map[string]interface{}), target.Parameters)
+	}
+	for key, value := range nodegroup.Extra {
+		if target.Extra == nil {
+			target.Extra = make(map[string]interface{})
+		}
+		target.Extra[key] = value
+	}
+	if target.Environment == "" {
+		target.Environment = nodegroup.Environment
+	}
+	for _, node := range nodegroup.Nodes {
+		if !containsString(target.Nodes, node) {
+			target.Nodes = append(target.Nodes, node)
+		}
+	}
+
+	enc.Nodegroups[into] = target
+	delete(enc.Nodegroups, name)
+
+	children := enc.reparentChildren(name, into)
+	enc.rebuildAllNodeChains()
+
+	return &NodegroupMerge{
+		Nodegroup: QualifyNodegroup(name, enc.Name),
+		Into:      QualifyNodegroup(into, enc.Name),
+		Children:  children,
+		Changes:   diffClassifications(before, enc.snapshotLinkedClassifications()),
+	}, nil
+}
+
+// qualifiedParent returns the parent of a nodegroup of the ENC as name@cluster, or "" without one
+func (enc *ENC) qualifiedParent(nodegroup Nodegroup) string {
+	if nodegroup.Parent == "" {
+		return ""
+	}
+
+	return QualifyNodegroup(nodegroup.Parent, enc.Name)
+}
+
+func (enc *ENC) checkNewNodegroupName(name string) error {
+	if name == "" || strings.Contains(name, "@") {
+		return fmt.Errorf("Nodegroup names can't be empty or contain @: %s", name)
+	}
+	if _, ok := enc.Nodegroups[name]; ok {
+		return fmt.Errorf("Nodegroup already exists: %s", name)
+	}
+
+	return nil
+}
+
+// reparentChildren points every nodegroup whose parent is oldName of this ENC at newName, keeping
+// parents in the same ENC unqualified if they were. It returns the qualified children.
+func (enc *ENC) reparentChildren(oldName string, newName string) []string {
+	encs := map[string]*ENC{enc.Name: enc}
+	if enc.ConfigLink != nil {
+		encs = enc.ConfigLink.ENCs
+	}
+
+	oldQualified := QualifyNodegroup(oldName, enc.Name)
+	children := make([]string, 0)
+	for encName, currentEnc := range encs {
+		for childName, child := range currentEnc.Nodegroups {
+			if child.Parent == "" || QualifyNodegroup(child.Parent, encName) != oldQualified {
+				continue
+			}
+
+			if strings.Contains(child.Parent, "@") {
+				child.Parent = QualifyNodegroup(newName, enc.Name)
+			} else {
+				child.Parent = newName
+			}
+			currentEnc.Nodegroups[childName] = child
+			children = append(children, QualifyNodegroup(childName, encName))
+		}
+	}
+	sort.Strings(children)
+
+	return children
+}
+
+// rebuildAllNodeChains rebuilds the node chains of every ENC, as chains name the parents of
+// nodegroups in other ENCs too
+func (enc *ENC) rebuildAllNodeChains() {
+	if enc.ConfigLink == nil {
+		enc.rebuildNodeChains()
+		return
+	}
+
+	for _, currentEnc := range enc.ConfigLink.ENCs {
+		currentEnc.rebuildNodeChains()
+	}
+}
+
+// nodegroupConflicts lists the classes, parameters and other keys two nodegroups both set to
+// different values, and their environments if they differ
+func nodegroupConflicts(a *Nodegroup, b *Nodegroup) ([]string, error) {
+	fieldsA, err := classificationFields(a)
+	if err != nil {
+		return []string{}, err
+	}
+	fieldsB, err := classificationFields(b)
+	if err != nil {
+		return []string{}, err
+	}
+
+	conflicts := make([]string, 0)
+	for field, valueA := range fieldsA {
+		if valueB, ok := fieldsB[field]; ok && valueA != valueB {
+			conflicts = append(conflicts, field)
+		}
+	}
+	for key, valueA := range a.Extra {
+		if valueB, ok := b.Extra[key]; ok && !sameYAMLValue(valueA, valueB) {
+			conflicts = append(conflicts, key)
+		}
+	}
+	sort.Strings(conflicts)
+
+	return conflicts, nil
+}
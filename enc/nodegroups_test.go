@@ -0,0 +1,119 @@
+package enc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenameNodegroup(t *testing.T) {
+	assert := assert.New(t)
+	c := newFixtureConfig()
+	production, staging := c.ENCs["production"], c.ENCs["staging"]
+
+	_, err := production.RenameNodegroup("website", "database")
+	assert.EqualError(err, "Nodegroup already exists: database")
+	_, err = production.RenameNodegroup("website", "frontend@staging")
+	assert.EqualError(err, "Nodegroup names can't be empty or contain @: frontend@staging")
+	_, err = production.RenameNodegroup("webserver", "frontend")
+	assert.EqualError(err, "Nodegroup does not exist: webserver")
+
+	children, err := production.RenameNodegroup("website", "frontend")
+	assert.Nil(err)
+	assert.Equal([]string{"staging_web@staging", "website_canary@production"}, children)
+	assert.NotContains(production.Nodegroups, "website")
+	assert.Equal([]string{"web-0001", "web-0002"}, production.Nodegroups["frontend"].Nodes)
+	assert.Equal("frontend@production", production.Nodegroups["website_canary"].Parent)
+	assert.Equal("frontend@production", staging.Nodegroups["staging_web"].Parent)
+
+	chains, _ := production.GetChains("web-0002")
+	assert.Equal([]string{"web-0002$$globals@production$$frontend@production"}, chains)
+	node, err := staging.GetNode("web-0101")
+	assert.Nil(err)
+	assert.Equal(map[string]interface{}{"worker_processes": 8}, node.Classes["nginx"])
+
+	// Parents in the same ENC stay unqualified
+	production.AddNodegroup("replica", "database", map[string]interface{}{}, []string{}, map[string]interface{}{})
+	production.RenameNodegroup("database", "db")
+	assert.Equal("db", production.Nodegroups["replica"].Parent)
+}
+
+func TestCopyNodegroup(t *testing.T) {
+	assert := assert.New(t)
+	c := newFixtureConfig()
+	production := c.ENCs["production"]
+
+	copied, err := production.CopyNodegroup("website", "website_blue", false)
+	assert.Nil(err)
+	assert.Equal("globals@production", copied.Parent)
+	assert.Empty(copied.Nodes)
+
+	// The copy's values aren't shared with the original
+	production.SetClassParameter("website_blue", "nginx", "worker_processes", 16)
+	assert.Equal(8, production.Nodegroups["website"].Classes["nginx"].(map[string]interface{})["worker_processes"])
+
+	_, err = production.CopyNodegroup("website", "website_green", true)
+	assert.Nil(err)
+	assert.Equal([]string{"website", "website_canary", "website_green"}, production.NodeNodegroups("web-0001"))
+	assert.NotContains(production.Nodegroups["website_canary"].Parent, "green")
+
+	_, err = production.CopyNodegroup("website", "website_green", true)
+	assert.EqualError(err, "Nodegroup already exists: website_green")
+}
+
+func TestMergeNodegroup(t *testing.T) {
+	assert := assert.New(t)
+	c := newFixtureConfig()
+	production := c.ENCs["production"]
+
+	production.AddNodegroup("web_extra", "globals@production", map[string]interface{}{
+		"haproxy": map[string]interface{}{"maxconn": 512},
+		"nginx":   map[string]interface{}{"worker_processes": 8, "gzip": true},
+	}, []string{}, map[string]interface{}{"tier": "web"})
+	production.AddNodes("web_extra", []string{"web-0002", "web-0003"})
+	production.AddNodegroup("web_extra_child", "web_extra", map[string]interface{}{}, []string{}, map[string]interface{}{})
+	production.AddNode("web_extra_child", "web-0004")
+
+	_, err := production.MergeNodegroup("website_canary", "database")
+	assert.EqualError(err, `Can't merge website_canary into database, they have different parents: "website@production" and "globals@production"`)
+	_, err = production.MergeNodegroup("website", "website_canary")
+	assert.EqualError(err, "Can't merge website into website_canary, which inherits from it")
+
+	production.AddNodegroup("web_small", "globals@production", map[string]interface{}{
+		"nginx": map[string]interface{}{"worker_processes": 2},
+	}, []string{}, map[string]interface{}{})
+	_, err = production.MergeNodegroup("web_small", "website")
+	assert.EqualError(err, "Nodegroups web_small and website set different values for: class.nginx.worker_processes")
+
+	// website inherits production from globals
+	production.AddNodegroup("web_testing", "globals@production", map[string]interface{}{}, []string{}, map[string]interface{}{})
+	production.SetEnvironment("web_testing", "testing")
+	_, err = production.MergeNodegroup("web_testing", "website")
+	assert.EqualError(err, "Nodegroups web_testing and website set different values for: environment")
+
+	merge, err := production.MergeNodegroup("web_extra", "website")
+	assert.Nil(err)
+	assert.Equal("web_extra@production", merge.Nodegroup)
+	assert.Equal("website@production", merge.Into)
+	assert.Equal([]string{"web_extra_child@production"}, merge.Children)
+	assert.Contains(merge.Changes, ClassificationChange{Node: "web-0001", Field: "class.haproxy", Change: "added"})
+	assert.Contains(merge.Changes, ClassificationChange{Node: "web-0101", Field: "param.tier", Change: "added", After: "web"})
+	for _, change := range merge.Changes {
+		assert.NotEqual("web-0003", change.Node)
+	}
+	assert.NotContains(production.Nodegroups, "web_extra")
+
+	website := production.Nodegroups["website"]
+	assert.Equal(map[string]interface{}{
+		"haproxy": map[string]interface{}{"maxconn": 512},
+		"nginx":   map[string]interface{}{"worker_processes": 8, "gzip": true},
+	}, website.Classes)
+	assert.Equal(map[string]interface{}{"tier": "web"}, website.Parameters)
+	assert.Equal([]string{"web-0001", "web-0002", "web-0003"}, website.Nodes)
+	assert.Equal("website", production.Nodegroups["web_extra_child"].Parent)
+
+	node, err := production.GetNode("web-0004")
+	assert.Nil(err)
+	assert.Contains(node.Classes, "haproxy")
+	assert.Equal("web", node.Parameters["tier"])
+}
@@ -0,0 +1,516 @@
+package enc
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// pathSegment is one step of a parameter path. index is the list index the step names, or -1 if
+// it can't be one, and inList is set for [n] steps, which are only ever list indexes.
+type pathSegment struct {
+	key    string
+	index  int
+	inList bool
+}
+
+// parsePath reads a parameter path: either dotted, with [n] for list items and \. for a dot in a
+// key, like "nginx.upstreams[0].port", or a JSON Pointer like "/nginx/upstreams/0/port"
+func parsePath(path string) ([]pathSegment, error) {
+	if path == "" {
+		return []pathSegment{}, errors.New("Empty parameter path")
+	}
+
+	if strings.HasPrefix(path, "/") {
+		segments := make([]pathSegment, 0)
+		for _, part := range strings.Split(path[1:], "/") {
+			key := strings.Replace(strings.Replace(part, "~1", "/", -1), "~0", "~", -1)
+			segments = append(segments, pathSegment{key: key, index: pathIndex(key)})
+		}
+		return segments, nil
+	}
+
+	segments := make([]pathSegment, 0)
+	runes := []rune(path)
+	key := make([]rune, 0)
+	// afterIndex is set straight after a [n], where a key can only follow a dot
+	afterIndex := false
+	for i := 0; i < len(runes); i++ {
+		switch r := runes[i]; {
+		case r == '\\' && i+1 < len(runes):
+			i++
+			key = append(key, runes[i])
+		case r == '.':
+			if len(key) == 0 && !afterIndex {
+				return []pathSegment{}, fmt.Errorf("Empty key in parameter path: %s", path)
+			}
+			if len(key) > 0 {
+				segments = append(segments, pathSegment{key: string(key), index: -1})
+			}
+			key = key[:0]
+			afterIndex = false
+			if i == len(runes)-1 {
+				return []pathSegment{}, fmt.Errorf("Empty key in parameter path: %s", path)
+			}
+		case r == '[':
+			if len(key) > 0 {
+				segments = append(segments, pathSegment{key: string(key), index: -1})
+				key = key[:0]
+			} else if len(segments) == 0 {
+				return []pathSegment{}, fmt.Errorf("Parameter path can't start with a list index: %s", path)
+			}
+
+			end := i + 1
+			for end < len(runes) && runes[end] != ']' {
+				end++
+			}
+			if end == len(runes) {
+				return []pathSegment{}, fmt.Errorf("Unclosed [ in parameter path: %s", path)
+			}
+
+			indexText := string(runes[i+1 : end])
+			index := pathIndex(indexText)
+			if index == -1 {
+				return []pathSegment{}, fmt.Errorf("List index must be a number: [%s] in %s", indexText, path)
+			}
+			segments = append(segments, pathSegment{key: indexText, index: index, inList: true})
+			i = end
+			afterIndex = true
+		default:
+			if afterIndex {
+				return []pathSegment{}, fmt.Errorf("Expecting . or [ after ] in parameter path: %s", path)
+			}
+			key = append(key, r)
+		}
+	}
+
+	if len(key) > 0 {
+		segments = append(segments, pathSegment{key: string(key), index: -1})
+	}
+
+	return segments, nil
+}
+
+func pathIndex(text string) int {
+	if text == "" || strings.TrimLeft(text, "0123456789") != "" {
+		return -1
+	}
+
+	index, err := strconv.Atoi(text)
+	if err != nil {
+		return -1
+	}
+
+	return index
+}
+
+// formatPath writes segments back out as a dotted path, for error messages
+func formatPath(segments []pathSegment) string {
+	if len(segments) == 0 {
+		return "the top level"
+	}
+
+	path := ""
+	for i, segment := range segments {
+		switch {
+		case segment.inList:
+			path += "[" + segment.key + "]"
+		case i > 0:
+			path += "." + strings.Replace(segment.key, ".", "\\.", -1)
+		default:
+			path += strings.Replace(segment.key, ".", "\\.", -1)
+		}
+	}
+
+	return path
+}
+
+func pathTypeName(value interface{}) string {
+	switch value.(type) {
+	case map[string]interface{}:
+		return "a map"
+	case []interface{}:
+		return "a list"
+	case string:
+		return "a string"
+	case bool:
+		return "a bool"
+	case nil:
+		return "null"
+	default:
+		return "a number"
+	}
+}
+
+// SetPath sets the value at path in data, creating the maps (or, for [n], lists) on the way that
+// don't exist yet. A list index can be one past the end, to add an item.
+func SetPath(data map[string]interface{}, path string, value interface{}) error {
+	segments, err := parsePath(path)
+	if err != nil {
+		return err
+	}
+
+	_, err = setPathValue(data, segments, value, []pathSegment{})
+	return err
+}
+
+// AddPath sets the value at path in data like SetPath, but only if nothing is there yet
+func AddPath(data map[string]interface{}, path string, value interface{}) error {
+	segments, err := parsePath(path)
+	if err != nil {
+		return err
+	}
+
+	if _, found, err := getPathValue(data, segments); err != nil {
+		return err
+	} else if found {
+		return fmt.Errorf("%s already exists", formatPath(segments))
+	}
+
+	_, err = setPathValue(data, segments, value, []pathSegment{})
+	return err
+}
+
+// setPathValue sets value below current, returning current, which is a new slice if a list grew
+func setPathValue(current interface{}, segments []pathSegment, value interface{}, walked []pathSegment) (interface{}, error) {
+	segment := segments[0]
+	walkedHere := append(walked[:len(walked):len(walked)], segment)
+
+	if current == nil {
+		if segment.inList {
+			current = []interface{}{}
+		} else {
+			current = map[string]interface{}{}
+		}
+	}
+
+	switch container := current.(type) {
+	case map[string]interface{}:
+		if segment.inList {
+			return current, fmt.Errorf("%s is a map, not a list", formatPath(walked))
+		}
+
+		if len(segments) == 1 {
+			container[segment.key] = value
+			return container, nil
+		}
+
+		child, err := setPathValue(container[segment.key], segments[1:], value, walkedHere)
+		if err != nil {
+			return current, err
+		}
+		container[segment.key] = child
+		return container, nil
+	case []interface{}:
+		if segment.index == -1 {
+			return current, fmt.Errorf("%s is a list, not a map", formatPath(walked))
+		}
+		if segment.index > len(container) {
+			return current, fmt.Errorf("%s has %d items, can't set [%d]", formatPath(walked), len(container), segment.index)
+		}
+		if segment.index == len(container) {
+			container = append(container, nil)
+		}
+
+		if len(segments) == 1 {
+			container[segment.index] = value
+			return container, nil
+		}
+
+		child, err := setPathValue(container[segment.index], segments[1:], value, walkedHere)
+		if err != nil {
+			return current, err
+		}
+		container[segment.index] = child
+		return container, nil
+	default:
+		return current, fmt.Errorf("%s is %s, not a map or list", formatPath(walked), pathTypeName(current))
+	}
+}
+
+// GetPath returns the value at path in data
+func GetPath(data map[string]interface{}, path string) (interface{}, error) {
+	segments, err := parsePath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	value, found, err := getPathValue(data, segments)
+	if err == nil && !found {
+		err = fmt.Errorf("%s does not exist", formatPath(segments))
+	}
+
+	return value, err
+}
+
+// getPathValue walks segments from current, found being false when the last map key or list
+// index on the way is missing
+func getPathValue(current interface{}, segments []pathSegment) (interface{}, bool, error) {
+	for i, segment := range segments {
+		switch container := current.(type) {
+		case map[string]interface{}:
+			if segment.inList {
+				return nil, false, fmt.Errorf("%s is a map, not a list", formatPath(segments[:i]))
+			}
+
+			value, ok := container[segment.key]
+			if !ok {
+				return nil, false, nil
+			}
+			current = value
+		case []interface{}:
+			if segment.index == -1 {
+				return nil, false, fmt.Errorf("%s is a list, not a map", formatPath(segments[:i]))
+			}
+			if segment.index >= len(container) {
+				return nil, false, nil
+			}
+			current = container[segment.index]
+		default:
+			if current == nil {
+				return nil, false, nil
+			}
+			return nil, false, fmt.Errorf("%s is %s, not a map or list", formatPath(segments[:i]), pathTypeName(current))
+		}
+	}
+
+	return current, true, nil
+}
+
+// DeletePath removes the value at path from data, taking items out of lists rather than leaving
+// a gap
+func DeletePath(data map[string]interface{}, path string) error {
+	segments, err := parsePath(path)
+	if err != nil {
+		return err
+	}
+
+	parentSegments, last := segments[:len(segments)-1], segments[len(segments)-1]
+	parent, found, err := getPathValue(data, parentSegments)
+	if err != nil {
+		return err
+	}
+
+	switch container := parent.(type) {
+	case map[string]interface{}:
+		if last.inList {
+			return fmt.Errorf("%s is a map, not a list", formatPath(parentSegments))
+		}
+		if _, ok := container[last.key]; ok {
+			delete(container, last.key)
+			return nil
+		}
+	case []interface{}:
+		if last.index == -1 {
+			return fmt.Errorf("%s is a list, not a map", formatPath(parentSegments))
+		}
+		if last.index < len(container) {
+			items := append(container[:last.index:last.index], container[last.index+1:]...)
+			_, err := setPathValue(data, parentSegments, items, []pathSegment{})
+			return err
+		}
+	default:
+		if found && parent != nil {
+			return fmt.Errorf("%s is %s, not a map or list", formatPath(parentSegments), pathTypeName(parent))
+		}
+	}
+
+	return fmt.Errorf("%s does not exist", formatPath(segments))
+}
+
+// AppendPath adds value to the end of the list at path in data, creating the list if it doesn't
+// exist yet
+func AppendPath(data map[string]interface{}, path string, value interface{}) error {
+	segments, err := parsePath(path)
+	if err != nil {
+		return err
+	}
+
+	current, found, err := getPathValue(data, segments)
+	if err != nil {
+		return err
+	}
+
+	list := []interface{}{}
+	if found && current != nil {
+		var ok bool
+		if list, ok = current.([]interface{}); !ok {
+			return fmt.Errorf("%s is %s, not a list", formatPath(segments), pathTypeName(current))
+		}
+	}
+
+	_, err = setPathValue(data, segments, append(list, value), []pathSegment{})
+	return err
+}
+
+// GetParameterPath returns a parameter of a nodegroup, or a value nested inside one with a path
+// like "nginx.upstreams[0].port"
+func (enc *ENC) GetParameterPath(nodegroupName string, path string) (interface{}, error) {
+	nodegroup, err := enc.GetNodegroup(nodegroupName)
+	if err != nil {
+		return nil, err
+	}
+
+	return GetPath(nodegroup.Parameters, path)
+}
+
+// SetParameterPath sets a parameter of a nodegroup, or a value nested inside one, creating the
+// maps on the way
+func (enc *ENC) SetParameterPath(nodegroupName string, path string, val interface{}) (*Nodegroup, error) {
+	return enc.updateParameters(nodegroupName, func(parameters map[string]interface{}) error {
+		return SetPath(parameters, path, val)
+	})
+}
+
+// AddParameterPath adds a parameter to a nodegroup, or a value nested inside one, failing if it
+// already exists
+func (enc *ENC) AddParameterPath(nodegroupName string, path string, val interface{}) (*Nodegroup, error) {
+	return enc.updateParameters(nodegroupName, func(parameters map[string]interface{}) error {
+		return AddPath(parameters, path, val)
+	})
+}
+
+// RemoveParameterPath removes a parameter of a nodegroup, or a value nested inside one
+func (enc *ENC) RemoveParameterPath(nodegroupName string, path string) (*Nodegroup, error) {
+	return enc.updateParameters(nodegroupName, func(parameters map[string]interface{}) error {
+		return DeletePath(parameters, path)
+	})
+}
+
+// AppendParameterPath adds a value to the end of a list parameter of a nodegroup, or a list nested
+// inside one
+func (enc *ENC) AppendParameterPath(nodegroupName string, path string, val interface{}) (*Nodegroup, error) {
+	return enc.updateParameters(nodegroupName, func(parameters map[string]interface{}) error {
+		return AppendPath(parameters, path, val)
+	})
+}
+
+func (enc *ENC) updateParameters(nodegroupName string, update func(map[string]interface{}) error) (*Nodegroup, error) {
+	nodegroup, err := enc.GetNodegroup(nodegroupName)
+	if err != nil {
+		return &Nodegroup{}, err
+	}
+
+	if nodegroup.Parameters == nil {
+		nodegroup.Parameters = make(map[string]interface{})
+	}
+
+	if err := update(nodegroup.Parameters); err != nil {
+		return &Nodegroup{}, err
+	}
+
+	enc.Nodegroups[nodegroupName] = *nodegroup
+	return nodegroup, nil
+}
+
+// GetClassParameterPath returns a parameter of a class on a nodegroup, or a value nested inside one
+func (enc *ENC) GetClassParameterPath(nodegroupName string, class string, path string) (interface{}, error) {
+	nodegroup, err := enc.GetNodegroup(nodegroupName)
+	if err != nil {
+		return nil, err
+	}
+
+	ngClass, err := classParameters(nodegroup, class)
+	if err != nil {
+		return nil, err
+	}
+
+	return GetPath(ngClass, path)
+}
+
+// SetClassParameterPath sets a parameter of a class on a nodegroup, or a value nested inside one
+func (enc *ENC) SetClassParameterPath(nodegroupName string, class string, path string, val interface{}) (*Nodegroup, error) {
+	nodegroup, err := enc.updateClassParameters(nodegroupName, class, func(ngClass map[string]interface{}) error {
+		return SetPath(ngClass, path, val)
+	})
+	if err == nil {
+		enc.checkCatalogPath(class, path)
+	}
+
+	return nodegroup, err
+}
+
+// AddClassParameterPath adds a parameter to a class on a nodegroup, or a value nested inside one,
+// failing if it already exists
+func (enc *ENC) AddClassParameterPath(nodegroupName string, class string, path string, val interface{}) (*Nodegroup, error) {
+	nodegroup, err := enc.updateClassParameters(nodegroupName, class, func(ngClass map[string]interface{}) error {
+		return AddPath(ngClass, path, val)
+	})
+	if err == nil {
+		enc.checkCatalogPath(class, path)
+	}
+
+	return nodegroup, err
+}
+
+// RemoveClassParameterPath removes a parameter of a class on a nodegroup, or a value nested inside one
+func (enc *ENC) RemoveClassParameterPath(nodegroupName string, class string, path string) (*Nodegroup, error) {
+	return enc.updateClassParameters(nodegroupName, class, func(ngClass map[string]interface{}) error {
+		return DeletePath(ngClass, path)
+	})
+}
+
+// AppendClassParameterPath adds a value to the end of a list parameter of a class on a nodegroup
+func (enc *ENC) AppendClassParameterPath(nodegroupName string, class string, path string, val interface{}) (*Nodegroup, error) {
+	nodegroup, err := enc.updateClassParameters(nodegroupName, class, func(ngClass map[string]interface{}) error {
+		return AppendPath(ngClass, path, val)
+	})
+	if err == nil {
+		enc.checkCatalogPath(class, path)
+	}
+
+	return nodegroup, err
+}
+
+func (enc *ENC) updateClassParameters(nodegroupName string, class string, update func(map[string]interface{}) error) (*Nodegroup, error) {
+	nodegroup, err := enc.GetNodegroup(nodegroupName)
+	if err != nil {
+		return &Nodegroup{}, err
+	}
+
+	ngClass, err := classParameters(nodegroup, class)
+	if err != nil {
+		return &Nodegroup{}, err
+	}
+
+	// Change a copy, so a change the class schema rejects leaves the class as it was
+	ngClass = copyValue(ngClass).(map[string]interface{})
+	if err := update(ngClass); err != nil {
+		return &Nodegroup{}, err
+	}
+
+	if err := enc.checkClassSchema(class, ngClass); err != nil {
+		return &Nodegroup{}, err
+	}
+
+	nodegroup.Classes[class] = ngClass
+	enc.Nodegroups[nodegroupName] = *nodegroup
+	return nodegroup, nil
+}
+
+// checkCatalogPath checks the class parameter a path starts with against the Puppet catalog
+func (enc *ENC) checkCatalogPath(class string, path string) {
+	if segments, err := parsePath(path); err == nil && len(segments) > 0 {
+		enc.checkCatalog(class, segments[0].key)
+	}
+}
+
+// classParameters returns the body of a class on a nodegroup, an empty map if it has none
+func classParameters(nodegroup *Nodegroup, class string) (map[string]interface{}, error) {
+	body, ok := nodegroup.Classes[class]
+	if !ok {
+		return map[string]interface{}{}, errors.New("Class does not exist on that nodegroup")
+	}
+
+	if body == nil {
+		return map[string]interface{}{}, nil
+	}
+
+	ngClass, ok := body.(map[string]interface{})
+	if !ok {
+		return map[string]interface{}{}, fmt.Errorf("Class %s is %s, not a map of parameters", class, pathTypeName(body))
+	}
+
+	return ngClass, nil
+}
@@ -0,0 +1,255 @@
+package enc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePath(t *testing.T) {
+	assert := assert.New(t)
+
+	segments, err := parsePath("nginx.upstreams[0].port")
+	assert.Nil(err)
+	assert.Equal([]pathSegment{
+		{key: "nginx", index: -1},
+		{key: "upstreams", index: -1},
+		{key: "0", index: 0, inList: true},
+		{key: "port", index: -1},
+	}, segments)
+
+	segments, err = parsePath("/nginx/upstreams/0/a~1b~0c")
+	assert.Nil(err)
+	assert.Equal([]pathSegment{
+		{key: "nginx", index: -1},
+		{key: "upstreams", index: -1},
+		{key: "0", index: 0},
+		{key: "a/b~c", index: -1},
+	}, segments)
+
+	segments, err = parsePath(`example\.com.ttl`)
+	assert.Nil(err)
+	assert.Equal([]pathSegment{{key: "example.com", index: -1}, {key: "ttl", index: -1}}, segments)
+	assert.Equal(`example\.com.ttl`, formatPath(segments))
+
+	segments, err = parsePath("matrix[1][2]")
+	assert.Nil(err)
+	assert.Equal("matrix[1][2]", formatPath(segments))
+
+	for _, bad := range []string{"", "a..b", "a.", ".a", "[0]", "a[x]", "a[0", "a[0]b"} {
+		_, err := parsePath(bad)
+		assert.NotNil(err, bad)
+	}
+}
+
+func TestSetPath(t *testing.T) {
+	assert := assert.New(t)
+
+	data := map[string]interface{}{
+		"nginx": map[string]interface{}{
+			"upstreams": []interface{}{
+				map[string]interface{}{"name": "app", "port": 8080},
+			},
+		},
+		"motd": "hello",
+	}
+
+	assert.Nil(SetPath(data, "nginx.upstreams[0].port", 9090))
+	assert.Nil(SetPath(data, "/nginx/upstreams/1", map[string]interface{}{"name": "api"}))
+	assert.Nil(SetPath(data, "nginx.workers", 4))
+	assert.Nil(SetPath(data, "ssl.certs[0]", "web.pem"))
+	assert.Nil(SetPath(data, "motd", "bye"))
+
+	assert.Equal(map[string]interface{}{
+		"nginx": map[string]interface{}{
+			"upstreams": []interface{}{
+				map[string]interface{}{"name": "app", "port": 9090},
+				map[string]interface{}{"name": "api"},
+			},
+			"workers": 4,
+		},
+		"ssl":  map[string]interface{}{"certs": []interface{}{"web.pem"}},
+		"motd": "bye",
+	}, data)
+
+	err := SetPath(data, "motd.text", "x")
+	assert.EqualError(err, "motd is a string, not a map or list")
+
+	err = SetPath(data, "nginx.upstreams.name", "x")
+	assert.EqualError(err, "nginx.upstreams is a list, not a map")
+
+	err = SetPath(data, "nginx[0]", "x")
+	assert.EqualError(err, "nginx is a map, not a list")
+
+	err = SetPath(data, "nginx.upstreams[5]", "x")
+	assert.EqualError(err, "nginx.upstreams has 2 items, can't set [5]")
+}
+
+func TestAddPath(t *testing.T) {
+	assert := assert.New(t)
+
+	data := map[string]interface{}{"nginx": map[string]interface{}{"workers": 4}}
+
+	assert.Nil(AddPath(data, "nginx.upstreams[0].port", 8080))
+	assert.Nil(AddPath(data, "motd", "hello"))
+	assert.EqualError(AddPath(data, "nginx.workers", 8), "nginx.workers already exists")
+	assert.EqualError(AddPath(data, "nginx.upstreams[0]", "x"), "nginx.upstreams[0] already exists")
+	assert.EqualError(AddPath(data, "motd.text", "x"), "motd is a string, not a map or list")
+
+	assert.Equal(map[string]interface{}{
+		"nginx": map[string]interface{}{
+			"workers":   4,
+			"upstreams": []interface{}{map[string]interface{}{"port": 8080}},
+		},
+		"motd": "hello",
+	}, data)
+}
+
+func TestSetParameterPath(t *testing.T) {
+	assert := assert.New(t)
+	c := newFixtureConfig()
+	production := c.ENCs["production"]
+
+	_, err := production.SetParameterPath("website", "limits.memory", "2G")
+	assert.Nil(err)
+	assert.Equal(map[string]interface{}{"memory": "2G"}, production.Nodegroups["website"].Parameters["limits"])
+
+	_, err = production.SetParameterPath("missing", "limits.memory", "2G")
+	assert.NotNil(err)
+
+	_, err = production.AddParameterPath("website", "limits.memory", "4G")
+	assert.EqualError(err, "limits.memory already exists")
+	_, err = production.AddParameterPath("website", "limits.cpu", 2)
+	assert.Nil(err)
+	assert.Equal(map[string]interface{}{"memory": "2G", "cpu": 2}, production.Nodegroups["website"].Parameters["limits"])
+
+	_, err = production.AddClass("website", "haproxy")
+	assert.Nil(err)
+	_, err = production.SetClassParameterPath("website", "haproxy", "backends[0].port", 80)
+	assert.Nil(err)
+	assert.Equal(map[string]interface{}{
+		"backends": []interface{}{map[string]interface{}{"port": 80}},
+	}, production.Nodegroups["website"].Classes["haproxy"])
+
+	_, err = production.SetClassParameterPath("website", "missing", "port", 80)
+	assert.NotNil(err)
+
+	_, err = production.AddClassParameterPath("website", "haproxy", "backends[0].port", 8080)
+	assert.EqualError(err, "backends[0].port already exists")
+}
+
+func TestGetPath(t *testing.T) {
+	assert := assert.New(t)
+
+	data := map[string]interface{}{
+		"nginx": map[string]interface{}{
+			"upstreams": []interface{}{
+				map[string]interface{}{"name": "app", "port": 8080},
+			},
+			"log": nil,
+		},
+	}
+
+	got, err := GetPath(data, "nginx.upstreams[0].port")
+	assert.Nil(err)
+	assert.Equal(8080, got)
+
+	got, err = GetPath(data, "/nginx/upstreams/0/name")
+	assert.Nil(err)
+	assert.Equal("app", got)
+
+	got, err = GetPath(data, "nginx.log")
+	assert.Nil(err)
+	assert.Nil(got)
+
+	_, err = GetPath(data, "nginx.upstreams[1]")
+	assert.EqualError(err, "nginx.upstreams[1] does not exist")
+
+	_, err = GetPath(data, "nginx.workers")
+	assert.EqualError(err, "nginx.workers does not exist")
+
+	_, err = GetPath(data, "nginx.upstreams[0].port.number")
+	assert.EqualError(err, "nginx.upstreams[0].port is a number, not a map or list")
+
+	_, err = GetPath(data, "nginx.upstreams.port")
+	assert.EqualError(err, "nginx.upstreams is a list, not a map")
+}
+
+func TestDeletePath(t *testing.T) {
+	assert := assert.New(t)
+
+	data := map[string]interface{}{
+		"nginx": map[string]interface{}{
+			"upstreams": []interface{}{"a", "b", "c"},
+			"workers":   4,
+		},
+		"motd": "hello",
+	}
+
+	assert.Nil(DeletePath(data, "nginx.upstreams[1]"))
+	assert.Nil(DeletePath(data, "/nginx/upstreams/1"))
+	assert.Nil(DeletePath(data, "nginx.workers"))
+	assert.Nil(DeletePath(data, "motd"))
+	assert.Equal(map[string]interface{}{
+		"nginx": map[string]interface{}{"upstreams": []interface{}{"a"}},
+	}, data)
+
+	assert.EqualError(DeletePath(data, "nginx.upstreams[3]"), "nginx.upstreams[3] does not exist")
+	assert.EqualError(DeletePath(data, "nginx.missing.key"), "nginx.missing.key does not exist")
+	assert.EqualError(DeletePath(data, "nginx.upstreams.name"), "nginx.upstreams is a list, not a map")
+	assert.EqualError(DeletePath(data, "nginx.upstreams[0].name"), "nginx.upstreams[0] is a string, not a map or list")
+	assert.EqualError(DeletePath(data, "nginx[0]"), "nginx is a map, not a list")
+}
+
+func TestAppendPath(t *testing.T) {
+	assert := assert.New(t)
+
+	data := map[string]interface{}{
+		"ntp":  map[string]interface{}{"servers": []interface{}{"0.pool.ntp.org"}},
+		"motd": "hello",
+	}
+
+	assert.Nil(AppendPath(data, "ntp.servers", "1.pool.ntp.org"))
+	assert.Nil(AppendPath(data, "dns.resolvers", "10.0.0.2"))
+	assert.Equal(map[string]interface{}{
+		"ntp":  map[string]interface{}{"servers": []interface{}{"0.pool.ntp.org", "1.pool.ntp.org"}},
+		"dns":  map[string]interface{}{"resolvers": []interface{}{"10.0.0.2"}},
+		"motd": "hello",
+	}, data)
+
+	assert.EqualError(AppendPath(data, "motd", "x"), "motd is a string, not a list")
+}
+
+func TestParameterPathMethods(t *testing.T) {
+	assert := assert.New(t)
+	c := newFixtureConfig()
+	production := c.ENCs["production"]
+
+	_, err := production.AppendParameterPath("website", "app.hosts", "web-0001")
+	assert.Nil(err)
+	got, err := production.GetParameterPath("website", "app.hosts[0]")
+	assert.Nil(err)
+	assert.Equal("web-0001", got)
+
+	_, err = production.RemoveParameterPath("website", "app.hosts[0]")
+	assert.Nil(err)
+	assert.Equal(map[string]interface{}{"hosts": []interface{}{}}, production.Nodegroups["website"].Parameters["app"])
+
+	_, err = production.RemoveParameterPath("website", "app.missing")
+	assert.NotNil(err)
+
+	_, err = production.AddClass("website", "haproxy")
+	assert.Nil(err)
+	_, err = production.AppendClassParameterPath("website", "haproxy", "/backends", "app")
+	assert.Nil(err)
+	got, err = production.GetClassParameterPath("website", "haproxy", "backends")
+	assert.Nil(err)
+	assert.Equal([]interface{}{"app"}, got)
+
+	_, err = production.RemoveClassParameterPath("website", "haproxy", "backends")
+	assert.Nil(err)
+	assert.Equal(map[string]interface{}{}, production.Nodegroups["website"].Classes["haproxy"])
+
+	_, err = production.GetClassParameterPath("website", "missing", "backends")
+	assert.EqualError(err, "Class does not exist on that nodegroup")
+}
@@ -0,0 +1,102 @@
+package enc
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// PrometheusTargetGroup is an entry of a Prometheus file_sd_configs file
+type PrometheusTargetGroup struct {
+	Targets []string          `json:"targets"`
+	Labels  map[string]string `json:"labels"`
+}
+
+var prometheusInvalidLabelCharacters = regexp.MustCompile("[^A-Za-z0-9_]")
+
+// PrometheusLabelName turns a parameter path like app.tier into a valid label name, app_tier
+func PrometheusLabelName(name string) string {
+	label := prometheusInvalidLabelCharacters.ReplaceAllString(name, "_")
+	if label == "" || (label[0] >= '0' && label[0] <= '9') {
+		label = "_" + label
+	}
+
+	return label
+}
+
+// PrometheusTargets turns every nodegroup with nodes into a target group labelled with the
+// nodegroup, ENC and environment, plus a label per parameter path in params (e.g. datacenter or
+// app.tier) that the nodegroup has or inherits. port is appended to each node when non-zero.
+// Nodegroups that can't be resolved are skipped with a warning.
+func (c *Config) PrometheusTargets(params []string, port int) ([]PrometheusTargetGroup, error) {
+	groups := make([]PrometheusTargetGroup, 0)
+
+	labelParams := make(map[string]string, len(params))
+	for _, param := range params {
+		label := PrometheusLabelName(param)
+		switch label {
+		case "nodegroup", "enc", "environment":
+			return groups, fmt.Errorf("Parameter %s clashes with the %s label", param, label)
+		}
+
+		if other, ok := labelParams[label]; ok && other != param {
+			return groups, fmt.Errorf("Parameters %s and %s both map to the label %s", other, param, label)
+		}
+		labelParams[label] = param
+	}
+
+	for _, encName := range c.ENCNames() {
+		currentEnc := c.ENCs[encName]
+		for _, name := range currentEnc.NodegroupNames() {
+			nodegroup, err := currentEnc.GetInheritedNodegroup(name)
+			if err != nil {
+				// One broken nodegroup shouldn't stop every other host being monitored
+				c.warn("Skipped nodegroup %s: %s", QualifyNodegroup(name, encName), err)
+				continue
+			}
+
+			if len(nodegroup.Nodes) == 0 {
+				continue
+			}
+
+			targets := make([]string, 0, len(nodegroup.Nodes))
+			for _, node := range nodegroup.Nodes {
+				if port != 0 {
+					node = fmt.Sprintf("%s:%d", node, port)
+				}
+				targets = append(targets, node)
+			}
+
+			labels := map[string]string{"nodegroup": name, "enc": encName}
+			if nodegroup.Environment != "" {
+				labels["environment"] = nodegroup.Environment
+			}
+
+			for label, param := range labelParams {
+				value, ok := lookupPath(nodegroup.Parameters, strings.Split(param, "."))
+				if !ok {
+					continue
+				}
+
+				if labels[label], err = prometheusLabelValue(value); err != nil {
+					return groups, err
+				}
+			}
+
+			groups = append(groups, PrometheusTargetGroup{Targets: targets, Labels: labels})
+		}
+	}
+
+	return groups, nil
+}
+
+// prometheusLabelValue uses strings as they are and encodes anything else as JSON
+func prometheusLabelValue(value interface{}) (string, error) {
+	if stringValue, ok := value.(string); ok {
+		return stringValue, nil
+	}
+
+	encoded, err := json.Marshal(value)
+	return string(encoded), err
+}
@@ -0,0 +1,76 @@
+package enc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrometheusLabelName(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("datacenter", PrometheusLabelName("datacenter"))
+	assert.Equal("app_tier", PrometheusLabelName("app.tier"))
+	assert.Equal("_1st_rack", PrometheusLabelName("1st-rack"))
+}
+
+func TestPrometheusTargets(t *testing.T) {
+	assert := assert.New(t)
+	c := newFixtureConfig()
+	c.ENCs["production"].AddParameter("database", "backup", map[string]interface{}{"hourly": true})
+
+	groups, err := c.PrometheusTargets([]string{"datacenter", "backup.hourly"}, 9100)
+	assert.Nil(err)
+
+	assert.Equal([]PrometheusTargetGroup{
+		{
+			Targets: []string{"db-0001:9100"},
+			Labels:  map[string]string{"nodegroup": "database", "enc": "production", "environment": "production", "datacenter": "dub1", "backup_hourly": "true"},
+		},
+		{
+			Targets: []string{"web-0001:9100", "web-0002:9100"},
+			Labels:  map[string]string{"nodegroup": "website", "enc": "production", "environment": "production", "datacenter": "dub1"},
+		},
+		{
+			Targets: []string{"web-0001:9100"},
+			Labels:  map[string]string{"nodegroup": "website_canary", "enc": "production", "environment": "canary", "datacenter": "dub1"},
+		},
+		{
+			Targets: []string{"web-0101:9100"},
+			Labels:  map[string]string{"nodegroup": "staging_web", "enc": "staging", "environment": "staging", "datacenter": "dub2"},
+		},
+	}, groups)
+
+	groups, err = c.PrometheusTargets([]string{}, 0)
+	assert.Nil(err)
+	assert.Equal([]string{"web-0001", "web-0002"}, groups[1].Targets)
+
+	c.ENCs["staging"].AddNodegroup("orphan", "missing@production", map[string]interface{}{}, []string{"orphan-0001"}, map[string]interface{}{})
+	groups, err = c.PrometheusTargets([]string{}, 0)
+	assert.Nil(err)
+	assert.Len(groups, 4)
+	assert.Equal([]string{"Skipped nodegroup orphan@staging: Nodegroup does not exist: missing@production"}, c.Warnings)
+
+	_, err = c.PrometheusTargets([]string{"enc"}, 0)
+	assert.NotNil(err)
+
+	_, err = c.PrometheusTargets([]string{"app.tier", "app-tier"}, 0)
+	assert.NotNil(err)
+}
+
+func TestGetInheritedNodegroup(t *testing.T) {
+	assert := assert.New(t)
+	c := newFixtureConfig()
+
+	nodegroup, err := c.ENCs["staging"].GetInheritedNodegroup("staging_web")
+	assert.Nil(err)
+	assert.Equal("website@production", nodegroup.Parent)
+	assert.Equal("staging", nodegroup.Environment)
+	assert.Equal([]string{"web-0101"}, nodegroup.Nodes)
+	assert.Equal(map[string]interface{}{"datacenter": "dub2"}, nodegroup.Parameters)
+	assert.Contains(nodegroup.Classes, "nginx")
+	assert.Contains(nodegroup.Classes, "ntp")
+
+	_, err = c.ENCs["staging"].GetInheritedNodegroup("nope")
+	assert.NotNil(err)
+}
@@ -0,0 +1,545 @@
+package enc
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// PuppetParameter is a parameter of a Puppet class, with its data type and default value as they're
+// written in the manifest. Default is empty when the parameter has none.
+type PuppetParameter struct {
+	Name    string `json:"name" yaml:"name"`
+	Type    string `json:"type,omitempty" yaml:"type,omitempty"`
+	Default string `json:"default,omitempty" yaml:"default,omitempty"`
+}
+
+// PuppetClass is a class defined in a Puppet manifest
+type PuppetClass struct {
+	Name       string            `json:"name" yaml:"name"`
+	File       string            `json:"file" yaml:"file"`
+	Parameters []PuppetParameter `json:"parameters" yaml:"parameters"`
+}
+
+// PuppetCatalog is every class found in a Puppet modules directory, by name
+type PuppetCatalog map[string]PuppetClass
+
+var (
+	puppetClassPattern     = regexp.MustCompile(`\bclass\s+([a-z][a-z0-9_]*(?:::[a-z][a-z0-9_]*)*)\s*(\(|\{|inherits\b)`)
+	puppetParameterPattern = regexp.MustCompile(`^\$([a-z_][a-zA-Z0-9_]*)`)
+)
+
+// ScanPuppetModules reads the class definitions of every manifest (.pp file) under dir
+func ScanPuppetModules(dir string) (PuppetCatalog, error) {
+	catalog := make(PuppetCatalog)
+
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || filepath.Ext(path) != ".pp" {
+			return nil
+		}
+
+		source, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		relative, err := filepath.Rel(dir, path)
+		if err != nil {
+			relative = path
+		}
+
+		classes, err := parsePuppetClasses(string(source), relative)
+		if err != nil {
+			return fmt.Errorf("%s: %s", relative, err)
+		}
+
+		for _, class := range classes {
+			// The first definition found wins, as Puppet would fail to compile with both anyway
+			if _, ok := catalog[class.Name]; !ok {
+				catalog[class.Name] = class
+			}
+		}
+		return nil
+	})
+
+	return catalog, err
+}
+
+// parsePuppetClasses finds the class definitions in the source of a manifest, with their parameters
+func parsePuppetClasses(source string, file string) ([]PuppetClass, error) {
+	code, masked := maskPuppetSource(source)
+	classes := make([]PuppetClass, 0)
+
+	for _, match := range puppetClassPattern.FindAllStringSubmatchIndex(masked, -1) {
+		// $class and Foo::class aren't definitions
+		if match[0] > 0 && (masked[match[0]-1] == '$' || masked[match[0]-1] == ':') {
+			continue
+		}
+
+		class := PuppetClass{Name: masked[match[2]:match[3]], File: file, Parameters: []PuppetParameter{}}
+		if masked[match[4]:match[5]] == "(" {
+			end := matchingBracket(masked, match[4])
+			if end < 0 {
+				return classes, fmt.Errorf("Parameters of class %s are never closed", class.Name)
+			}
+
+			for _, span := range splitTopLevel(masked, match[4]+1, end) {
+				parameter, ok := parsePuppetParameter(code[span[0]:span[1]], masked[span[0]:span[1]])
+				if ok {
+					class.Parameters = append(class.Parameters, parameter)
+				}
+			}
+		}
+
+		classes = append(classes, class)
+	}
+
+	return classes, nil
+}
+
+// maskPuppetSource returns the source with comments blanked out, and a copy with the contents of
+// strings and regular expressions blanked out too, so neither hides brackets or commas. Both are
+// the same length as source.
+func maskPuppetSource(source string) (string, string) {
+	code := []byte(source)
+	masked := []byte(source)
+	blank := func(buffer []byte, from int, to int) {
+		for i := from; i < to && i < len(buffer); i++ {
+			if buffer[i] != '\n' {
+				buffer[i] = ' '
+			}
+		}
+	}
+
+	var last byte
+	for i := 0; i < len(source); {
+		c := source[i]
+		switch {
+		case c == '#':
+			end := strings.IndexByte(source[i:], '\n')
+			if end < 0 {
+				end = len(source) - i
+			}
+			blank(code, i, i+end)
+			blank(masked, i, i+end)
+			i += end
+			continue
+		case c == '/' && i+1 < len(source) && source[i+1] == '*':
+			end := len(source)
+			if close := strings.Index(source[i+2:], "*/"); close >= 0 {
+				end = i + 2 + close + 2
+			}
+			blank(code, i, end)
+			blank(masked, i, end)
+			i = end
+			continue
+		case c == '\'' || c == '"' || (c == '/' && (last == '[' || last == ',')):
+			end := i + 1
+			for end < len(source) && source[end] != c {
+				if source[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			blank(masked, i+1, end)
+			last = c
+			i = end + 1
+			continue
+		}
+
+		if c != ' ' && c != '\t' && c != '\n' && c != '\r' {
+			last = c
+		}
+		i++
+	}
+
+	return string(code), string(masked)
+}
+
+// matchingBracket returns the index of the bracket closing the one at open, or -1
+func matchingBracket(masked string, open int) int {
+	depth := 0
+	for i := open; i < len(masked); i++ {
+		switch masked[i] {
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+
+	return -1
+}
+
+// splitTopLevel returns the spans between the commas from start to end that aren't inside brackets
+func splitTopLevel(masked string, start int, end int) [][2]int {
+	spans := make([][2]int, 0)
+	depth := 0
+	from := start
+	for i := start; i < end; i++ {
+		switch masked[i] {
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			depth--
+		case ',':
+			if depth == 0 {
+				spans = append(spans, [2]int{from, i})
+				from = i + 1
+			}
+		}
+	}
+
+	return append(spans, [2]int{from, end})
+}
+
+// parsePuppetParameter reads a single "Type $name = default", ok being false if there's no parameter
+func parsePuppetParameter(code string, masked string) (PuppetParameter, bool) {
+	dollar := strings.IndexByte(masked, '$')
+	if dollar < 0 {
+		return PuppetParameter{}, false
+	}
+
+	match := puppetParameterPattern.FindStringSubmatch(masked[dollar:])
+	if match == nil {
+		return PuppetParameter{}, false
+	}
+
+	parameter := PuppetParameter{
+		Name: match[1],
+		Type: compactPuppetType(code[:dollar]),
+	}
+
+	rest := strings.TrimSpace(code[dollar+len(match[0]):])
+	if strings.HasPrefix(rest, "=") {
+		parameter.Default = strings.TrimSpace(rest[1:])
+	}
+
+	return parameter, true
+}
+
+// compactPuppetType drops the white space and line breaks a type is written with, outside of quotes
+func compactPuppetType(text string) string {
+	compact := make([]byte, 0, len(text))
+	var quote byte
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && i+1 < len(text) {
+				compact = append(compact, c)
+				i++
+				c = text[i]
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || (c == '/' && len(compact) > 0 && (compact[len(compact)-1] == '[' || compact[len(compact)-1] == ' ')):
+			quote = c
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			continue
+		case c == ',':
+			compact = append(compact, ',', ' ')
+			continue
+		}
+		compact = append(compact, c)
+	}
+
+	return string(compact)
+}
+
+// Parameter returns the parameter of the class with that name
+func (class PuppetClass) Parameter(name string) (PuppetParameter, bool) {
+	for _, parameter := range class.Parameters {
+		if parameter.Name == name {
+			return parameter, true
+		}
+	}
+
+	return PuppetParameter{}, false
+}
+
+// Schema describes the parameters of the class as a class schema, allowing no others. Parameters
+// without a default are usually set through Hiera, so they're only required when required is set,
+// and then only if their type doesn't accept undef.
+func (class PuppetClass) Schema(required bool) Schema {
+	properties := make(map[string]interface{}, len(class.Parameters))
+	requiredNames := make([]interface{}, 0)
+
+	for _, parameter := range class.Parameters {
+		parameterType := parsePuppetType(parameter.Type)
+		property := puppetTypeSchema(parameterType)
+		if parameter.Default != "" {
+			if value, ok := puppetLiteral(parameter.Default); ok {
+				property["default"] = value
+			}
+		} else if required && !parameterType.acceptsUndef() {
+			requiredNames = append(requiredNames, parameter.Name)
+		}
+		properties[parameter.Name] = property
+	}
+
+	schema := Schema{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+	if len(requiredNames) > 0 {
+		schema["required"] = requiredNames
+	}
+
+	return schema
+}
+
+// Schemas returns the schema of every class in the catalog, see PuppetClass.Schema
+func (catalog PuppetCatalog) Schemas(required bool) map[string]Schema {
+	schemas := make(map[string]Schema, len(catalog))
+	for name, class := range catalog {
+		schemas[name] = class.Schema(required)
+	}
+
+	return schemas
+}
+
+// WriteSchemas writes the schema of every class in the catalog to dir, as files LoadClassSchemas reads
+func (catalog PuppetCatalog) WriteSchemas(dir string, required bool) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	for name, schema := range catalog.Schemas(required) {
+		contents, err := json.MarshalIndent(schema, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		fileName := filepath.Join(dir, strings.Replace(name, "::", "__", -1)+".json")
+		if err := ioutil.WriteFile(fileName, append(contents, '\n'), 0644); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// puppetType is a Puppet data type, like Array[String, 1]. Arguments that aren't types (numbers,
+// strings, regular expressions and default) only have a name, which is how they're written.
+type puppetType struct {
+	name string
+	args []puppetType
+}
+
+func parsePuppetType(text string) puppetType {
+	parsed, _ := parsePuppetTypeAt(text, 0)
+	return parsed
+}
+
+// parsePuppetTypeAt parses the type starting at position i of text, returning where it ends
+func parsePuppetTypeAt(text string, i int) (puppetType, int) {
+	for i < len(text) && text[i] == ' ' {
+		i++
+	}
+
+	start := i
+	if i < len(text) && (text[i] == '\'' || text[i] == '"' || text[i] == '/') {
+		quote := text[i]
+		for i++; i < len(text) && text[i] != quote; i++ {
+			if text[i] == '\\' {
+				i++
+			}
+		}
+		return puppetType{name: text[start:minInt(i+1, len(text))]}, i + 1
+	}
+	if i < len(text) && text[i] == '{' {
+		end := matchingBracket(text, i)
+		if end < 0 {
+			end = len(text) - 1
+		}
+		return puppetType{name: text[start : end+1]}, end + 1
+	}
+
+	for i < len(text) && !strings.ContainsRune("[],", rune(text[i])) {
+		i++
+	}
+	parsed := puppetType{name: strings.TrimSpace(text[start:i])}
+
+	if i < len(text) && text[i] == '[' {
+		i++
+		for i < len(text) && text[i] != ']' {
+			var arg puppetType
+			arg, i = parsePuppetTypeAt(text, i)
+			parsed.args = append(parsed.args, arg)
+			for i < len(text) && (text[i] == ',' || text[i] == ' ') {
+				i++
+			}
+		}
+		i++
+	}
+
+	return parsed, i
+}
+
+func minInt(a int, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// acceptsUndef reports whether a parameter of the type can be left out without a default
+func (t puppetType) acceptsUndef() bool {
+	switch t.name {
+	case "Optional", "Undef":
+		return true
+	case "Variant":
+		for _, arg := range t.args {
+			if arg.acceptsUndef() {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// puppetTypeSchema translates a Puppet data type into a schema. Types with no equivalent, like Any
+// or the Stdlib aliases, accept anything.
+func puppetTypeSchema(t puppetType) map[string]interface{} {
+	schema := make(map[string]interface{})
+	bound := func(keyword string, index int) {
+		if index < len(t.args) {
+			if value, ok := puppetLiteral(t.args[index].name); ok {
+				if _, isNumber := schemaNumber(value); isNumber {
+					schema[keyword] = value
+				}
+			}
+		}
+	}
+
+	switch t.name {
+	case "String":
+		schema["type"] = "string"
+		bound("minLength", 0)
+		bound("maxLength", 1)
+	case "Integer":
+		schema["type"] = "integer"
+		bound("minimum", 0)
+		bound("maximum", 1)
+	case "Float", "Numeric":
+		schema["type"] = "number"
+		bound("minimum", 0)
+		bound("maximum", 1)
+	case "Boolean":
+		schema["type"] = "boolean"
+	case "Undef":
+		schema["type"] = "null"
+	case "Array", "Tuple":
+		schema["type"] = "array"
+		if t.name == "Array" && len(t.args) > 0 {
+			schema["items"] = puppetTypeSchema(t.args[0])
+			bound("minItems", 1)
+			bound("maxItems", 2)
+		}
+	case "Hash", "Struct":
+		schema["type"] = "object"
+		if t.name == "Hash" && len(t.args) > 1 {
+			schema["additionalProperties"] = puppetTypeSchema(t.args[1])
+		}
+	case "Enum":
+		options := make([]interface{}, 0, len(t.args))
+		for _, arg := range t.args {
+			if value, ok := puppetLiteral(arg.name); ok {
+				options = append(options, value)
+			}
+		}
+		schema["enum"] = options
+	case "Pattern":
+		patterns := make([]interface{}, 0, len(t.args))
+		for _, arg := range t.args {
+			if strings.HasPrefix(arg.name, "/") && len(arg.name) > 1 {
+				pattern := strings.Replace(strings.TrimSuffix(arg.name[1:], "/"), `\/`, "/", -1)
+				patterns = append(patterns, map[string]interface{}{"pattern": pattern})
+			}
+		}
+		schema["type"] = "string"
+		if len(patterns) == 1 {
+			schema["pattern"] = patterns[0].(map[string]interface{})["pattern"]
+		} else if len(patterns) > 1 {
+			schema["anyOf"] = patterns
+		}
+	case "Optional":
+		if len(t.args) > 0 {
+			schema["anyOf"] = []interface{}{puppetTypeSchema(t.args[0]), map[string]interface{}{"type": "null"}}
+		}
+	case "NotUndef":
+		if len(t.args) > 0 {
+			return puppetTypeSchema(t.args[0])
+		}
+	case "Variant":
+		options := make([]interface{}, 0, len(t.args))
+		for _, arg := range t.args {
+			options = append(options, puppetTypeSchema(arg))
+		}
+		schema["anyOf"] = options
+	}
+
+	return schema
+}
+
+// puppetLiteral reads a simple Puppet value: a string, number, boolean, undef or an empty array or
+// hash. ok is false for anything else, like variables or function calls.
+func puppetLiteral(text string) (interface{}, bool) {
+	switch {
+	case text == "undef":
+		return nil, true
+	case text == "true" || text == "false":
+		return text == "true", true
+	case text == "[]":
+		return []interface{}{}, true
+	case text == "{}":
+		return map[string]interface{}{}, true
+	case len(text) >= 2 && text[0] == '\'' && text[len(text)-1] == '\'':
+		unescaped := strings.NewReplacer(`\\`, `\`, `\'`, `'`).Replace(text[1 : len(text)-1])
+		return unescaped, true
+	case len(text) >= 2 && text[0] == '"' && text[len(text)-1] == '"' && !strings.Contains(text, "$"):
+		unquoted, err := strconv.Unquote(text)
+		return unquoted, err == nil
+	}
+
+	if number, err := strconv.Atoi(text); err == nil {
+		return number, true
+	}
+	if number, err := strconv.ParseFloat(text, 64); err == nil {
+		return number, true
+	}
+
+	return nil, false
+}
+
+// checkCatalog warns when a class, or a parameter of it, isn't in the Puppet catalog, if one is
+// loaded. An empty parameter only checks the class.
+func (enc *ENC) checkCatalog(className string, parameter string) {
+	if enc.ConfigLink == nil || enc.ConfigLink.PuppetCatalog == nil {
+		return
+	}
+
+	class, ok := enc.ConfigLink.PuppetCatalog[className]
+	if !ok {
+		enc.ConfigLink.warn("Class %s isn't defined in the Puppet modules", className)
+		return
+	}
+
+	if _, ok := class.Parameter(parameter); parameter != "" && !ok {
+		enc.ConfigLink.warn("Class %s has no parameter %s", className, parameter)
+	}
+}
@@ -0,0 +1,155 @@
+package enc
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const nginxManifest = `# Manages nginx (class nginx::old ($x) lives elsewhere)
+class nginx (
+  Integer[1, 64] $worker_processes = 4,
+  Enum['main', 'json'] $log_format = 'main',
+  Optional[String[1]] $server_name, # the default vhost
+  Array[
+    String
+  ] $modules = [],
+  Pattern[/^\/etc\/[a-z,]+$/] $conf_dir = "/etc/nginx",
+  Variant[Boolean, Enum['on', 'off']] $ssl = false,
+  String $package = $nginx::params::package,
+  $user,
+) inherits nginx::params {
+  class { 'nginx::service': }
+}
+
+class nginx::service {
+  service { 'nginx': ensure => running }
+}
+`
+
+func TestParsePuppetClasses(t *testing.T) {
+	assert := assert.New(t)
+
+	classes, err := parsePuppetClasses(nginxManifest, "nginx/manifests/init.pp")
+	assert.Nil(err)
+	assert.Equal([]PuppetClass{
+		{
+			Name: "nginx",
+			File: "nginx/manifests/init.pp",
+			Parameters: []PuppetParameter{
+				{Name: "worker_processes", Type: "Integer[1, 64]", Default: "4"},
+				{Name: "log_format", Type: "Enum['main', 'json']", Default: "'main'"},
+				{Name: "server_name", Type: "Optional[String[1]]"},
+				{Name: "modules", Type: "Array[String]", Default: "[]"},
+				{Name: "conf_dir", Type: `Pattern[/^\/etc\/[a-z,]+$/]`, Default: `"/etc/nginx"`},
+				{Name: "ssl", Type: "Variant[Boolean, Enum['on', 'off']]", Default: "false"},
+				{Name: "package", Type: "String", Default: "$nginx::params::package"},
+				{Name: "user"},
+			},
+		},
+		{Name: "nginx::service", File: "nginx/manifests/init.pp", Parameters: []PuppetParameter{}},
+	}, classes)
+
+	_, err = parsePuppetClasses("class broken (String $x = 'a' {", "broken.pp")
+	assert.EqualError(err, "Parameters of class broken are never closed")
+}
+
+func TestPuppetClassSchema(t *testing.T) {
+	assert := assert.New(t)
+
+	classes, _ := parsePuppetClasses(nginxManifest, "init.pp")
+	schema := classes[0].Schema(true)
+	assert.Equal(Schema{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"worker_processes": map[string]interface{}{"type": "integer", "minimum": 1, "maximum": 64, "default": 4},
+			"log_format":       map[string]interface{}{"enum": []interface{}{"main", "json"}, "default": "main"},
+			"server_name": map[string]interface{}{"anyOf": []interface{}{
+				map[string]interface{}{"type": "string", "minLength": 1},
+				map[string]interface{}{"type": "null"},
+			}},
+			"modules":  map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}, "default": []interface{}{}},
+			"conf_dir": map[string]interface{}{"type": "string", "pattern": "^/etc/[a-z,]+$", "default": "/etc/nginx"},
+			"ssl": map[string]interface{}{"anyOf": []interface{}{
+				map[string]interface{}{"type": "boolean"},
+				map[string]interface{}{"enum": []interface{}{"on", "off"}},
+			}, "default": false},
+			"package": map[string]interface{}{"type": "string"},
+			"user":    map[string]interface{}{},
+		},
+		"required":             []interface{}{"user"},
+		"additionalProperties": false,
+	}, schema)
+
+	assert.Empty(schema.Validate(map[string]interface{}{"user": "www-data", "ssl": "on", "modules": []interface{}{"geoip"}}, false))
+	assert.Equal([]SchemaViolation{{Path: "worker_processes", Message: "should be at most 64"}},
+		schema.Validate(map[string]interface{}{"user": "www-data", "worker_processes": 128}, false))
+
+	// Without required, parameters Hiera fills in can be left out
+	schema = classes[0].Schema(false)
+	assert.NotContains(schema, "required")
+	assert.Equal(false, schema["additionalProperties"])
+	assert.Empty(schema.Validate(map[string]interface{}{}, false))
+	assert.Equal([]SchemaViolation{{Path: "worker_processes", Message: "should be at most 64"}},
+		schema.Validate(map[string]interface{}{"worker_processes": 128}, false))
+}
+
+func TestScanPuppetModules(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "go-enc-modules")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	os.MkdirAll(filepath.Join(dir, "nginx", "manifests"), 0755)
+	os.MkdirAll(filepath.Join(dir, "ntp", "manifests"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "nginx", "manifests", "init.pp"), []byte(nginxManifest), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "ntp", "manifests", "init.pp"), []byte("class ntp(Array[String] $servers = []) {}\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "ntp", "README.md"), []byte("class readme ($x) {}"), 0644)
+
+	catalog, err := ScanPuppetModules(dir)
+	assert.Nil(err)
+	assert.Len(catalog, 3)
+	assert.Equal(filepath.Join("ntp", "manifests", "init.pp"), catalog["ntp"].File)
+	assert.Equal([]PuppetParameter{{Name: "servers", Type: "Array[String]", Default: "[]"}}, catalog["ntp"].Parameters)
+
+	schemaDir := filepath.Join(dir, "schemas")
+	assert.Nil(catalog.WriteSchemas(schemaDir, false))
+	schemas, err := LoadClassSchemas(schemaDir)
+	assert.Nil(err)
+	assert.Len(schemas, 3)
+	assert.Contains(schemas, "nginx::service")
+}
+
+func TestCatalogWarnings(t *testing.T) {
+	assert := assert.New(t)
+	c := newFixtureConfig()
+	production := c.ENCs["production"]
+
+	// Without a catalog nothing is checked
+	production.AddClass("website", "apache")
+	assert.Empty(c.Warnings)
+
+	classes, _ := parsePuppetClasses(nginxManifest, "init.pp")
+	c.PuppetCatalog = PuppetCatalog{"nginx": classes[0], "nginx::service": classes[1]}
+
+	production.AddClass("website", "nginx::service")
+	production.AddClass("website", "nginx::vhost")
+	production.AddClassParameter("website", "nginx", "worker_processes", 4)
+	production.AddClassParameter("website", "nginx", "worker_procs", 4)
+	production.SetClassParameterPath("website", "nginx", "upstreams[0].host", "app")
+	production.AppendClassParameterPath("website", "nginx", "modules", "geoip")
+
+	assert.Equal([]string{
+		"Class nginx::vhost isn't defined in the Puppet modules",
+		"Class nginx has no parameter worker_procs",
+		"Class nginx has no parameter upstreams",
+	}, c.Warnings)
+
+	// Warnings don't stop the change
+	assert.Equal(4, production.Nodegroups["website"].Classes["nginx"].(map[string]interface{})["worker_procs"])
+}
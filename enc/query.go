@@ -0,0 +1,435 @@
+package enc
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Query is a parsed filter expression that can be matched against nodegroups and nodes.
+//
+// A filter is made of predicates joined with "and", "or", "not" and parentheses. A predicate
+// is a field on its own (the field must exist) or a field, an operator and a value:
+//
+//	class.nginx                              class is applied
+//	class.nginx.worker_processes > 4         class parameter comparison
+//	param.datacenter = dub1                  parameter equality
+//	param.hostname =~ "^web-[0-9]+$"         regular expression
+//	environment != production                environment of the nodegroup/node
+//	name =~ ^web and enc = example_cluster   name of the nodegroup/node and its ENC
+//
+// Supported operators are =, ==, !=, <, <=, >, >=, =~ and !~. Numbers are compared
+// numerically, everything else as strings. When the field holds a list, = and =~ match if any
+// element matches.
+type Query struct {
+	Expression string
+	root       queryExpr
+}
+
+// QueryResult is a single nodegroup or node matched by a query
+type QueryResult struct {
+	ENC       string     `json:"enc" yaml:"enc"`
+	Name      string     `json:"name" yaml:"name"`
+	Nodegroup *Nodegroup `json:"nodegroup" yaml:"nodegroup"`
+}
+
+// queryTarget is what a query is evaluated against
+type queryTarget struct {
+	enc       string
+	name      string
+	nodegroup *Nodegroup
+}
+
+type queryExpr interface {
+	match(target *queryTarget) bool
+}
+
+type queryAnd struct{ left, right queryExpr }
+
+type queryOr struct{ left, right queryExpr }
+
+type queryNot struct{ expr queryExpr }
+
+type queryPredicate struct {
+	field    []string
+	operator string
+	value    string
+	regex    *regexp.Regexp
+}
+
+type queryMatchAll struct{}
+
+func (q queryAnd) match(target *queryTarget) bool {
+	return q.left.match(target) && q.right.match(target)
+}
+func (q queryOr) match(target *queryTarget) bool {
+	return q.left.match(target) || q.right.match(target)
+}
+func (q queryNot) match(target *queryTarget) bool    { return !q.expr.match(target) }
+func (queryMatchAll) match(target *queryTarget) bool { return true }
+
+// ParseQuery parses a filter expression. An empty expression matches everything.
+func ParseQuery(expression string) (*Query, error) {
+	tokens, err := tokeniseQuery(expression)
+	if err != nil {
+		return &Query{}, err
+	}
+
+	if len(tokens) == 0 {
+		return &Query{Expression: expression, root: queryMatchAll{}}, nil
+	}
+
+	parser := &queryParser{tokens: tokens}
+	root, err := parser.parseOr()
+	if err != nil {
+		return &Query{}, err
+	}
+
+	if parser.pos < len(parser.tokens) {
+		return &Query{}, fmt.Errorf("Unexpected %q in query", parser.tokens[parser.pos].text)
+	}
+
+	return &Query{Expression: expression, root: root}, nil
+}
+
+// Match reports whether a nodegroup (or a node's merged nodegroup) satisfies the query
+func (q *Query) Match(encName string, name string, nodegroup *Nodegroup) bool {
+	return q.root.match(&queryTarget{enc: encName, name: name, nodegroup: nodegroup})
+}
+
+// QueryNodegroups returns every nodegroup, across all ENCs, whose own values match the query
+func (c *Config) QueryNodegroups(expression string) ([]QueryResult, error) {
+	query, err := ParseQuery(expression)
+	if err != nil {
+		return []QueryResult{}, err
+	}
+
+	results := make([]QueryResult, 0)
+	for _, encName := range c.ENCNames() {
+		currentEnc := c.ENCs[encName]
+		for _, name := range currentEnc.NodegroupNames() {
+			nodegroup := currentEnc.Nodegroups[name]
+			if query.Match(encName, name, &nodegroup) {
+				results = append(results, QueryResult{ENC: encName, Name: name, Nodegroup: &nodegroup})
+			}
+		}
+	}
+
+	return results, nil
+}
+
+// QueryNodes returns every node, across all ENCs, whose classification from GetNode matches
+// the query
+func (c *Config) QueryNodes(expression string) ([]QueryResult, error) {
+	query, err := ParseQuery(expression)
+	if err != nil {
+		return []QueryResult{}, err
+	}
+
+	results := make([]QueryResult, 0)
+	for _, encName := range c.ENCNames() {
+		currentEnc := c.ENCs[encName]
+		for _, nodeName := range currentEnc.NodeNames() {
+			node, nodeErr := currentEnc.GetNode(nodeName)
+			if nodeErr != nil {
+				return []QueryResult{}, fmt.Errorf("Could not classify node %s in %s: %s", nodeName, encName, nodeErr)
+			}
+
+			if query.Match(encName, nodeName, node) {
+				results = append(results, QueryResult{ENC: encName, Name: nodeName, Nodegroup: node})
+			}
+		}
+	}
+
+	return results, nil
+}
+
+func (p queryPredicate) match(target *queryTarget) bool {
+	value, found := p.resolve(target)
+	if !found {
+		// Negative operators hold for fields that don't exist
+		return p.operator == "!=" || p.operator == "!~"
+	}
+
+	if p.operator == "" {
+		return true
+	}
+
+	if list, ok := value.([]interface{}); ok && p.operator != "!=" && p.operator != "!~" {
+		for _, item := range list {
+			if p.compare(item) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if list, ok := value.([]interface{}); ok {
+		for _, item := range list {
+			if !p.compare(item) {
+				return false
+			}
+		}
+		return true
+	}
+
+	return p.compare(value)
+}
+
+// resolve finds the value of the predicate's field on the target
+func (p queryPredicate) resolve(target *queryTarget) (interface{}, bool) {
+	switch p.field[0] {
+	case "name":
+		return target.name, true
+	case "enc":
+		return target.enc, true
+	case "parent":
+		return target.nodegroup.Parent, target.nodegroup.Parent != ""
+	case "environment", "env":
+		return target.nodegroup.Environment, target.nodegroup.Environment != ""
+	case "class", "classes":
+		return lookupPath(target.nodegroup.Classes, p.field[1:])
+	case "param", "parameter", "parameters":
+		return lookupPath(target.nodegroup.Parameters, p.field[1:])
+	}
+
+	return nil, false
+}
+
+func (p queryPredicate) compare(value interface{}) bool {
+	stringValue := fmt.Sprintf("%v", value)
+
+	switch p.operator {
+	case "=~":
+		return p.regex.MatchString(stringValue)
+	case "!~":
+		return !p.regex.MatchString(stringValue)
+	}
+
+	// Prefer a numeric comparison when both sides are numbers
+	cmp := strings.Compare(stringValue, p.value)
+	if left, leftErr := strconv.ParseFloat(stringValue, 64); leftErr == nil {
+		if right, rightErr := strconv.ParseFloat(p.value, 64); rightErr == nil {
+			switch {
+			case left < right:
+				cmp = -1
+			case left > right:
+				cmp = 1
+			default:
+				cmp = 0
+			}
+		}
+	}
+
+	switch p.operator {
+	case "=", "==":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	}
+
+	return false
+}
+
+// lookupPath walks nested maps following path, returning the value found at the end
+func lookupPath(data map[string]interface{}, path []string) (interface{}, bool) {
+	var current interface{} = data
+	for _, key := range path {
+		currentMap, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+
+		if current, ok = currentMap[key]; !ok {
+			return nil, false
+		}
+	}
+
+	return current, true
+}
+
+type queryToken struct {
+	text   string
+	quoted bool
+}
+
+func tokeniseQuery(expression string) ([]queryToken, error) {
+	tokens := make([]queryToken, 0)
+	runes := []rune(expression)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(' || r == ')':
+			tokens = append(tokens, queryToken{text: string(r)})
+			i++
+		case r == '"' || r == '\'':
+			end := i + 1
+			for end < len(runes) && runes[end] != r {
+				if runes[end] == '\\' && end+1 < len(runes) {
+					end++
+				}
+				end++
+			}
+			if end >= len(runes) {
+				return []queryToken{}, fmt.Errorf("Unterminated string in query: %s", string(runes[i:]))
+			}
+			text := strings.Replace(string(runes[i+1:end]), "\\"+string(r), string(r), -1)
+			tokens = append(tokens, queryToken{text: text, quoted: true})
+			i = end + 1
+		case strings.ContainsRune("=!<>~", r):
+			end := i
+			for end < len(runes) && strings.ContainsRune("=!<>~", runes[end]) {
+				end++
+			}
+			tokens = append(tokens, queryToken{text: string(runes[i:end])})
+			i = end
+		default:
+			end := i
+			for end < len(runes) && !unicode.IsSpace(runes[end]) && !strings.ContainsRune("()=!<>~\"'", runes[end]) {
+				end++
+			}
+			tokens = append(tokens, queryToken{text: string(runes[i:end])})
+			i = end
+		}
+	}
+
+	return tokens, nil
+}
+
+var queryOperators = map[string]bool{
+	"=": true, "==": true, "!=": true, "<": true, "<=": true, ">": true, ">=": true, "=~": true, "!~": true,
+}
+
+type queryParser struct {
+	tokens []queryToken
+	pos    int
+}
+
+func (p *queryParser) peekKeyword(keyword string) bool {
+	return p.pos < len(p.tokens) && !p.tokens[p.pos].quoted && strings.ToLower(p.tokens[p.pos].text) == keyword
+}
+
+func (p *queryParser) parseOr() (queryExpr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peekKeyword("or") {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = queryOr{left, right}
+	}
+
+	return left, nil
+}
+
+func (p *queryParser) parseAnd() (queryExpr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peekKeyword("and") {
+		p.pos++
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = queryAnd{left, right}
+	}
+
+	return left, nil
+}
+
+func (p *queryParser) parseUnary() (queryExpr, error) {
+	if p.pos >= len(p.tokens) {
+		return nil, fmt.Errorf("Unexpected end of query")
+	}
+
+	if p.peekKeyword("not") {
+		p.pos++
+		expr, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return queryNot{expr}, nil
+	}
+
+	if p.peekKeyword("(") {
+		p.pos++
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.peekKeyword(")") {
+			return nil, fmt.Errorf("Missing closing bracket in query")
+		}
+		p.pos++
+		return expr, nil
+	}
+
+	return p.parsePredicate()
+}
+
+func (p *queryParser) parsePredicate() (queryExpr, error) {
+	fieldToken := p.tokens[p.pos]
+	if fieldToken.quoted || queryOperators[fieldToken.text] || fieldToken.text == ")" {
+		return nil, fmt.Errorf("Expected a field but found %q in query", fieldToken.text)
+	}
+	p.pos++
+
+	predicate := queryPredicate{field: strings.Split(fieldToken.text, ".")}
+	switch predicate.field[0] {
+	case "name", "enc", "parent", "environment", "env":
+		if len(predicate.field) > 1 {
+			return nil, fmt.Errorf("Field %s has no sub-keys: %s", predicate.field[0], fieldToken.text)
+		}
+	case "class", "classes", "param", "parameter", "parameters":
+		if len(predicate.field) < 2 {
+			return nil, fmt.Errorf("Field %s needs a name, e.g. %s.example", predicate.field[0], predicate.field[0])
+		}
+	default:
+		return nil, fmt.Errorf("Unknown query field: %s", fieldToken.text)
+	}
+
+	if p.pos >= len(p.tokens) || p.tokens[p.pos].quoted || !queryOperators[p.tokens[p.pos].text] {
+		// No operator, so this is an existence check
+		return predicate, nil
+	}
+
+	predicate.operator = p.tokens[p.pos].text
+	p.pos++
+
+	if p.pos >= len(p.tokens) {
+		return nil, fmt.Errorf("Missing value after %s in query", predicate.operator)
+	}
+	predicate.value = p.tokens[p.pos].text
+	p.pos++
+
+	if predicate.operator == "=~" || predicate.operator == "!~" {
+		regex, err := regexp.Compile(predicate.value)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid regular expression in query: %s", err)
+		}
+		predicate.regex = regex
+	}
+
+	return predicate, nil
+}
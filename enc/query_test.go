@@ -0,0 +1,90 @@
+package enc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func queryResultNames(results []QueryResult) []string {
+	names := make([]string, 0, len(results))
+	for _, result := range results {
+		names = append(names, result.Name+"@"+result.ENC)
+	}
+
+	return names
+}
+
+func TestParseQuery(t *testing.T) {
+	assert := assert.New(t)
+
+	validQueries := []string{
+		"",
+		"class.nginx",
+		"class.nginx.worker_processes > 4",
+		"param.datacenter = dub1 and not environment = canary",
+		"(name =~ '^web-' or name == db-0001) and enc != staging",
+		`param.datacenter =~ "dub[0-9]"`,
+	}
+	for _, query := range validQueries {
+		_, err := ParseQuery(query)
+		assert.Nil(err, query)
+	}
+
+	invalidQueries := []string{
+		"class",
+		"unknown_field = 1",
+		"name.sub = 1",
+		"class.nginx >",
+		"(class.nginx",
+		"class.nginx class.mysql",
+		"name =~ '('",
+		"name = 'unterminated",
+	}
+	for _, query := range invalidQueries {
+		_, err := ParseQuery(query)
+		assert.NotNil(err, query)
+	}
+}
+
+func TestQueryNodegroups(t *testing.T) {
+	assert := assert.New(t)
+	c := newFixtureConfig()
+
+	results, err := c.QueryNodegroups("param.datacenter")
+	assert.Nil(err)
+	assert.Equal([]string{"globals@production", "staging_web@staging"}, queryResultNames(results))
+
+	results, err = c.QueryNodegroups("class.nginx.worker_processes >= 4")
+	assert.Nil(err)
+	assert.Equal([]string{"website@production"}, queryResultNames(results))
+
+	results, err = c.QueryNodegroups("parent =~ ^website@")
+	assert.Nil(err)
+	assert.Equal([]string{"website_canary@production", "staging_web@staging"}, queryResultNames(results))
+
+	results, err = c.QueryNodegroups("")
+	assert.Nil(err)
+	assert.Len(results, 5)
+}
+
+func TestQueryNodes(t *testing.T) {
+	assert := assert.New(t)
+	c := newFixtureConfig()
+
+	results, err := c.QueryNodes("class.nginx.worker_processes > 4")
+	assert.Nil(err)
+	assert.Equal([]string{"web-0002@production", "web-0101@staging"}, queryResultNames(results))
+
+	results, err = c.QueryNodes("class.nginx and environment = canary")
+	assert.Nil(err)
+	assert.Equal([]string{"web-0001@production"}, queryResultNames(results))
+
+	results, err = c.QueryNodes("param.datacenter = dub1 and not class.nginx")
+	assert.Nil(err)
+	assert.Equal([]string{"db-0001@production"}, queryResultNames(results))
+
+	results, err = c.QueryNodes("class.ntp.servers = 1.pool.ntp.org and enc = staging")
+	assert.Nil(err)
+	assert.Equal([]string{"web-0101@staging"}, queryResultNames(results))
+}
@@ -0,0 +1,184 @@
+package enc
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// SaltPillar is the pillar SLS of a nodegroup, including the pillars of its parent chain
+type SaltPillar struct {
+	Include    []string
+	Parameters map[string]interface{}
+}
+
+// SaltExport is every ENC laid out as a Salt state top file and a pillar top file, by node, and a
+// pillar SLS per nodegroup, keyed by SLS name
+type SaltExport struct {
+	Top       map[string][]string
+	PillarTop map[string][]string
+	Pillars   map[string]*SaltPillar
+}
+
+// SaltStateName turns a Puppet class name into a Salt state name, e.g. nginx::config to nginx.config
+func SaltStateName(class string) string {
+	return strings.Replace(class, "::", ".", -1)
+}
+
+// SaltPillarName turns name@cluster into a pillar SLS name, cluster.name
+func SaltPillarName(nodegroupName string, encName string) string {
+	nameSplit := strings.SplitN(QualifyNodegroup(nodegroupName, encName), "@", 2)
+	return strings.Replace(nameSplit[1], ".", "_", -1) + "." + strings.Replace(nameSplit[0], ".", "_", -1)
+}
+
+// SaltExport builds Salt top files and pillars from every ENC. Each node with nodegroups is a target
+// of its own, using the list matcher, applying a state per class along their parent chains and
+// their pillars from the least specific nodegroup to the most, so a child's pillar comes after its
+// parent's and overrides it. Each pillar includes the pillars of its chain from the top-most parent
+// down too.
+func (c *Config) SaltExport() *SaltExport {
+	export := &SaltExport{
+		Top:       make(map[string][]string),
+		PillarTop: make(map[string][]string),
+		Pillars:   make(map[string]*SaltPillar),
+	}
+
+	nodeNodegroups := make(map[string][]saltNodegroup)
+	for _, encName := range c.ENCNames() {
+		currentEnc := c.ENCs[encName]
+		for _, name := range currentEnc.NodegroupNames() {
+			nodegroup := currentEnc.Nodegroups[name]
+			pillarName := SaltPillarName(name, encName)
+			chain := currentEnc.QualifiedParentChain(name)
+
+			pillar := &SaltPillar{Include: []string{}, Parameters: nodegroup.Parameters}
+			states := []string{}
+			for _, ancestor := range chain {
+				ancestorNodegroup, err := currentEnc.GetNodegroup(ancestor)
+				if err != nil {
+					// Broken chains still export what they can
+					continue
+				}
+
+				if ancestorPillar := SaltPillarName(ancestor, encName); ancestorPillar != pillarName {
+					pillar.Include = append(pillar.Include, ancestorPillar)
+				}
+
+				for _, class := range sortedKeys(ancestorNodegroup.Classes) {
+					states = appendUnique(states, SaltStateName(class))
+				}
+			}
+			export.Pillars[pillarName] = pillar
+
+			for _, node := range nodegroup.Nodes {
+				nodeNodegroups[node] = append(nodeNodegroups[node], saltNodegroup{depth: len(chain), pillar: pillarName, states: states})
+			}
+		}
+	}
+
+	for node, nodegroups := range nodeNodegroups {
+		sort.Sort(bySaltSpecificity(nodegroups))
+		for _, nodegroup := range nodegroups {
+			for _, state := range nodegroup.states {
+				export.Top[node] = appendUnique(export.Top[node], state)
+			}
+			export.PillarTop[node] = appendUnique(export.PillarTop[node], nodegroup.pillar)
+		}
+	}
+
+	return export
+}
+
+// saltNodegroup is a nodegroup a node is in, with the length of its parent chain
+type saltNodegroup struct {
+	depth  int
+	pillar string
+	states []string
+}
+
+// bySaltSpecificity orders nodegroups least specific first, then by pillar name
+type bySaltSpecificity []saltNodegroup
+
+func (s bySaltSpecificity) Len() int      { return len(s) }
+func (s bySaltSpecificity) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s bySaltSpecificity) Less(i, j int) bool {
+	if s[i].depth != s[j].depth {
+		return s[i].depth < s[j].depth
+	}
+	return s[i].pillar < s[j].pillar
+}
+
+// WriteOut writes salt/top.sls, pillar/top.sls and pillar/<cluster>/<nodegroup>.sls into dir
+func (export *SaltExport) WriteOut(dir string) error {
+	for _, subdir := range []string{"salt", "pillar"} {
+		if err := os.MkdirAll(filepath.Join(dir, subdir), 0755); err != nil {
+			return err
+		}
+	}
+
+	if err := writeYAMLFile(filepath.Join(dir, "salt", "top.sls"), saltTopFile(export.Top)); err != nil {
+		return err
+	}
+
+	if err := writeYAMLFile(filepath.Join(dir, "pillar", "top.sls"), saltTopFile(export.PillarTop)); err != nil {
+		return err
+	}
+
+	for name, pillar := range export.Pillars {
+		path := filepath.Join(dir, "pillar", filepath.FromSlash(strings.Replace(name, ".", "/", 1))+".sls")
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+
+		if err := writeYAMLFile(path, pillar); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// MarshalYAML puts the includes before the parameters, as Salt expects
+func (pillar *SaltPillar) MarshalYAML() (interface{}, error) {
+	contents := yaml.MapSlice{}
+	if len(pillar.Include) > 0 {
+		if _, ok := pillar.Parameters["include"]; ok {
+			return nil, fmt.Errorf("Parameter \"include\" clashes with the pillar includes")
+		}
+		contents = append(contents, yaml.MapItem{Key: "include", Value: pillar.Include})
+	}
+
+	for _, key := range sortedKeys(pillar.Parameters) {
+		contents = append(contents, yaml.MapItem{Key: key, Value: pillar.Parameters[key]})
+	}
+
+	return contents, nil
+}
+
+// saltTopFile lays out targets for the base environment using the list matcher
+func saltTopFile(top map[string][]string) map[string]interface{} {
+	targets := make(map[string]interface{}, len(top))
+	for target, slsNames := range top {
+		entries := []interface{}{map[string]string{"match": "list"}}
+		for _, sls := range slsNames {
+			entries = append(entries, sls)
+		}
+		targets[target] = entries
+	}
+
+	return map[string]interface{}{"base": targets}
+}
+
+func writeYAMLFile(path string, v interface{}) error {
+	contents, err := yaml.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, contents, 0644)
+}
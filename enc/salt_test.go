@@ -0,0 +1,88 @@
+package enc
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSaltNames(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("nginx.config", SaltStateName("nginx::config"))
+	assert.Equal("production.website", SaltPillarName("website", "production"))
+	assert.Equal("production.website", SaltPillarName("website@production", "staging"))
+	assert.Equal("eu-west_1.web_canary", SaltPillarName("web.canary", "eu-west.1"))
+}
+
+func TestSaltExport(t *testing.T) {
+	assert := assert.New(t)
+	c := newFixtureConfig()
+
+	export := c.SaltExport()
+
+	assert.Len(export.Pillars, 5)
+	assert.Equal([]string{}, export.Pillars["production.globals"].Include)
+	assert.Equal([]string{"production.globals", "production.website"}, export.Pillars["staging.staging_web"].Include)
+	assert.Equal(map[string]interface{}{"datacenter": "dub2"}, export.Pillars["staging.staging_web"].Parameters)
+
+	assert.Equal(map[string][]string{
+		"web-0001": {"ntp", "nginx"},
+		"web-0002": {"ntp", "nginx"},
+		"db-0001":  {"ntp", "mysql"},
+		"web-0101": {"ntp", "nginx"},
+	}, export.Top)
+	assert.Equal([]string{"production.website", "production.website_canary"}, export.PillarTop["web-0001"])
+	assert.Equal([]string{"production.website"}, export.PillarTop["web-0002"])
+	assert.Equal([]string{"staging.staging_web"}, export.PillarTop["web-0101"])
+
+	// A child's pillar comes after its parent's, whatever their names
+	c.ENCs["production"].AddNodegroup("a_canary", "website@production", map[string]interface{}{
+		"haproxy": map[string]interface{}{},
+	}, []string{"web-0002"}, map[string]interface{}{})
+	export = c.SaltExport()
+	assert.Equal([]string{"production.website", "production.a_canary"}, export.PillarTop["web-0002"])
+	assert.Equal([]string{"ntp", "nginx", "haproxy"}, export.Top["web-0002"])
+}
+
+func TestSaltExportWriteOut(t *testing.T) {
+	assert := assert.New(t)
+	c := newFixtureConfig()
+
+	dir, err := ioutil.TempDir("", "go-enc-salt")
+	assert.Nil(err)
+	defer os.RemoveAll(dir)
+
+	assert.Nil(c.SaltExport().WriteOut(dir))
+
+	contents, err := ioutil.ReadFile(filepath.Join(dir, "pillar", "production", "website_canary.sls"))
+	assert.Nil(err)
+	assert.Equal("include:\n- production.globals\n- production.website\n", string(contents))
+
+	contents, err = ioutil.ReadFile(filepath.Join(dir, "salt", "top.sls"))
+	assert.Nil(err)
+	assert.Equal(`base:
+  db-0001:
+  - match: list
+  - ntp
+  - mysql
+  web-0001:
+  - match: list
+  - ntp
+  - nginx
+  web-0002:
+  - match: list
+  - ntp
+  - nginx
+  web-0101:
+  - match: list
+  - ntp
+  - nginx
+`, string(contents))
+
+	c.ENCs["production"].AddParameter("database", "include", "mysql")
+	assert.NotNil(c.SaltExport().WriteOut(dir))
+}
@@ -0,0 +1,397 @@
+package enc
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Schema is a JSON Schema for the parameters of a Puppet class. go-enc checks type, enum, const,
+// properties, required, additionalProperties, items, minItems, maxItems, minLength, maxLength,
+// pattern, minimum, maximum, exclusiveMinimum, exclusiveMaximum, anyOf, oneOf and allOf, and
+// ignores other keywords.
+type Schema map[string]interface{}
+
+// SchemaViolation is a value that doesn't match its class schema
+type SchemaViolation struct {
+	ENC       string `json:"enc,omitempty" yaml:"enc,omitempty"`
+	Nodegroup string `json:"nodegroup,omitempty" yaml:"nodegroup,omitempty"`
+	Node      string `json:"node,omitempty" yaml:"node,omitempty"`
+	Class     string `json:"class" yaml:"class"`
+	Path      string `json:"path" yaml:"path"`
+	Message   string `json:"message" yaml:"message"`
+}
+
+func (violation SchemaViolation) Error() string {
+	if violation.Class == "" {
+		return violation.Message
+	}
+	if violation.Path == "" {
+		return fmt.Sprintf("Class %s: %s", violation.Class, violation.Message)
+	}
+
+	return fmt.Sprintf("Class %s: %s: %s", violation.Class, violation.Path, violation.Message)
+}
+
+// LoadClassSchemas reads the JSON (or YAML) schema files in dir, one per class, named after the
+// class with :: written as __ where the file system needs it, e.g. nginx__vhost.json
+func LoadClassSchemas(dir string) (map[string]Schema, error) {
+	schemas := make(map[string]Schema)
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return schemas, err
+	}
+
+	for _, file := range files {
+		format, formatErr := FormatForFile(file.Name())
+		if file.IsDir() || formatErr != nil || (format != "json" && format != "yaml") {
+			continue
+		}
+
+		data, err := ioutil.ReadFile(filepath.Join(dir, file.Name()))
+		if err != nil {
+			return schemas, err
+		}
+
+		value, err := ParseValue(string(data), format)
+		if err != nil {
+			return schemas, fmt.Errorf("Schema %s: %s", file.Name(), err)
+		}
+
+		schema, ok := value.(map[string]interface{})
+		if !ok {
+			return schemas, fmt.Errorf("Schema %s should be an object", file.Name())
+		}
+
+		class := strings.TrimSuffix(file.Name(), filepath.Ext(file.Name()))
+		schemas[strings.Replace(class, "__", "::", -1)] = Schema(schema)
+	}
+
+	return schemas, nil
+}
+
+// Validate checks value against the schema. With partial, required properties may be missing, as
+// a nodegroup can leave them to its children or parents.
+func (schema Schema) Validate(value interface{}, partial bool) []SchemaViolation {
+	violations := make([]SchemaViolation, 0)
+	validateSchema(schema, value, "", partial, &violations)
+	return violations
+}
+
+func validateSchema(schemaValue interface{}, value interface{}, path string, partial bool, violations *[]SchemaViolation) {
+	fail := func(format string, args ...interface{}) {
+		*violations = append(*violations, SchemaViolation{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	// true and false are schemas that match everything and nothing
+	if allowed, ok := schemaValue.(bool); ok {
+		if !allowed {
+			fail("is not allowed")
+		}
+		return
+	}
+
+	// Secrets can't be checked without decrypting them, nor, in nodegroups, references that are
+	// only interpolated for nodes
+	if IsSecret(value) {
+		return
+	}
+	if str, ok := value.(string); ok && partial && interpolationPattern.MatchString(str) {
+		return
+	}
+
+	var schema map[string]interface{}
+	switch val := schemaValue.(type) {
+	case Schema:
+		schema = val
+	case map[string]interface{}:
+		schema = val
+	default:
+		return
+	}
+
+	if types, ok := schema["type"]; ok && !schemaTypeMatches(types, value) {
+		fail("should be %s, not %s", schemaTypeNames(types), schemaTypeOf(value))
+		return
+	}
+
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		found := false
+		for _, option := range enum {
+			found = found || sameYAMLValue(option, value)
+		}
+		if !found {
+			fail("should be one of %s", schemaValueList(enum))
+		}
+	}
+
+	if constValue, ok := schema["const"]; ok && !sameYAMLValue(constValue, value) {
+		fail("should be %s", schemaValueList([]interface{}{constValue}))
+	}
+
+	switch val := value.(type) {
+	case string:
+		length := float64(utf8.RuneCountInString(val))
+		if min, ok := schemaNumber(schema["minLength"]); ok && length < min {
+			fail("should be at least %v characters", min)
+		}
+		if max, ok := schemaNumber(schema["maxLength"]); ok && length > max {
+			fail("should be at most %v characters", max)
+		}
+		if pattern, ok := schema["pattern"].(string); ok {
+			if matcher, err := regexp.Compile(pattern); err != nil {
+				fail("schema pattern is not valid: %s", err)
+			} else if !matcher.MatchString(val) {
+				fail("should match %s", pattern)
+			}
+		}
+	case []interface{}:
+		count := float64(len(val))
+		if min, ok := schemaNumber(schema["minItems"]); ok && count < min {
+			fail("should have at least %v items", min)
+		}
+		if max, ok := schemaNumber(schema["maxItems"]); ok && count > max {
+			fail("should have at most %v items", max)
+		}
+		if items, ok := schema["items"]; ok {
+			for i, item := range val {
+				validateSchema(items, item, fmt.Sprintf("%s[%d]", path, i), partial, violations)
+			}
+		}
+	case map[string]interface{}:
+		properties, _ := schema["properties"].(map[string]interface{})
+		if required, ok := schema["required"].([]interface{}); ok && !partial {
+			for _, name := range required {
+				if _, ok := val[fmt.Sprint(name)]; !ok {
+					fail("is missing required parameter %s", name)
+				}
+			}
+		}
+		for _, key := range sortedKeys(val) {
+			keyPath := schemaPath(path, key)
+			if property, ok := properties[key]; ok {
+				validateSchema(property, val[key], keyPath, partial, violations)
+				continue
+			}
+
+			switch additional := schema["additionalProperties"].(type) {
+			case bool:
+				if !additional {
+					*violations = append(*violations, SchemaViolation{Path: keyPath, Message: "is not a known parameter"})
+				}
+			case map[string]interface{}:
+				validateSchema(additional, val[key], keyPath, partial, violations)
+			}
+		}
+	default:
+		if number, ok := schemaNumber(value); ok {
+			if min, ok := schemaNumber(schema["minimum"]); ok && number < min {
+				fail("should be at least %v", min)
+			}
+			if max, ok := schemaNumber(schema["maximum"]); ok && number > max {
+				fail("should be at most %v", max)
+			}
+			if min, ok := schemaNumber(schema["exclusiveMinimum"]); ok && number <= min {
+				fail("should be more than %v", min)
+			}
+			if max, ok := schemaNumber(schema["exclusiveMaximum"]); ok && number >= max {
+				fail("should be less than %v", max)
+			}
+		}
+	}
+
+	if allOf, ok := schema["allOf"].([]interface{}); ok {
+		for _, option := range allOf {
+			validateSchema(option, value, path, partial, violations)
+		}
+	}
+
+	if anyOf, ok := schema["anyOf"].([]interface{}); ok && schemaMatches(anyOf, value, partial) == 0 {
+		fail("doesn't match any of the allowed schemas")
+	}
+
+	if oneOf, ok := schema["oneOf"].([]interface{}); ok {
+		if matches := schemaMatches(oneOf, value, partial); matches != 1 {
+			fail("should match exactly one of the allowed schemas, matches %d", matches)
+		}
+	}
+}
+
+// schemaMatches counts the schemas value is valid against
+func schemaMatches(schemas []interface{}, value interface{}, partial bool) int {
+	matches := 0
+	for _, option := range schemas {
+		optionViolations := make([]SchemaViolation, 0)
+		validateSchema(option, value, "", partial, &optionViolations)
+		if len(optionViolations) == 0 {
+			matches++
+		}
+	}
+
+	return matches
+}
+
+func schemaPath(path string, key string) string {
+	key = strings.Replace(key, ".", "\\.", -1)
+	if path == "" {
+		return key
+	}
+
+	return path + "." + key
+}
+
+func schemaNumber(value interface{}) (float64, bool) {
+	switch val := value.(type) {
+	case int:
+		return float64(val), true
+	case int64:
+		return float64(val), true
+	case float64:
+		return val, true
+	default:
+		return 0, false
+	}
+}
+
+func schemaTypeOf(value interface{}) string {
+	switch value.(type) {
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case nil:
+		return "null"
+	}
+
+	if number, ok := schemaNumber(value); ok && number == math.Trunc(number) {
+		return "integer"
+	}
+
+	return "number"
+}
+
+func schemaTypeMatches(types interface{}, value interface{}) bool {
+	valueType := schemaTypeOf(value)
+	for _, name := range schemaTypeList(types) {
+		if name == valueType || (name == "number" && valueType == "integer") {
+			return true
+		}
+	}
+
+	return false
+}
+
+func schemaTypeList(types interface{}) []string {
+	switch val := types.(type) {
+	case string:
+		return []string{val}
+	case []interface{}:
+		names := make([]string, 0, len(val))
+		for _, name := range val {
+			names = append(names, fmt.Sprint(name))
+		}
+		return names
+	default:
+		return []string{}
+	}
+}
+
+func schemaTypeNames(types interface{}) string {
+	return strings.Join(schemaTypeList(types), " or ")
+}
+
+func schemaValueList(values []interface{}) string {
+	items := make([]string, 0, len(values))
+	for _, value := range values {
+		if str, ok := value.(string); ok {
+			items = append(items, fmt.Sprintf("%q", str))
+		} else {
+			items = append(items, fmt.Sprint(value))
+		}
+	}
+
+	return strings.Join(items, ", ")
+}
+
+// ValidateClass checks the body of a class against its schema, if the config has one for it
+func (c *Config) ValidateClass(class string, body interface{}, partial bool) []SchemaViolation {
+	schema, ok := c.ClassSchemas[class]
+	if !ok {
+		return []SchemaViolation{}
+	}
+
+	if body == nil {
+		body = map[string]interface{}{}
+	}
+
+	violations := schema.Validate(body, partial)
+	for i := range violations {
+		violations[i].Class = class
+	}
+
+	return violations
+}
+
+// ValidateClassSchemas checks every class of every nodegroup, and of every node once resolved,
+// against the class schemas. Nodegroups can leave required parameters out, nodes can't.
+func (c *Config) ValidateClassSchemas() []SchemaViolation {
+	violations := make([]SchemaViolation, 0)
+
+	for _, encName := range c.ENCNames() {
+		currentEnc := c.ENCs[encName]
+		for _, name := range currentEnc.NodegroupNames() {
+			nodegroup := currentEnc.Nodegroups[name]
+			for _, class := range sortedKeys(nodegroup.Classes) {
+				for _, violation := range c.ValidateClass(class, nodegroup.Classes[class], true) {
+					violation.ENC, violation.Nodegroup = encName, name
+					violations = append(violations, violation)
+				}
+			}
+		}
+
+		for _, nodeName := range currentEnc.NodeNames() {
+			node, err := currentEnc.GetNode(nodeName)
+			if err != nil {
+				violations = append(violations, SchemaViolation{ENC: encName, Node: nodeName, Message: err.Error()})
+				continue
+			}
+
+			for _, class := range sortedKeys(node.Classes) {
+				for _, violation := range c.ValidateClass(class, node.Classes[class], false) {
+					violation.ENC, violation.Node = encName, nodeName
+					violations = append(violations, violation)
+				}
+			}
+		}
+	}
+
+	return violations
+}
+
+// checkClassSchema returns the problems with a class body written to a nodegroup, if any
+func (enc *ENC) checkClassSchema(class string, body interface{}) error {
+	if enc.ConfigLink == nil {
+		return nil
+	}
+
+	violations := enc.ConfigLink.ValidateClass(class, body, true)
+	if len(violations) == 0 {
+		return nil
+	}
+
+	messages := make([]string, 0, len(violations))
+	for _, violation := range violations {
+		messages = append(messages, violation.Error())
+	}
+
+	return fmt.Errorf("Schema validation failed: %s", strings.Join(messages, "; "))
+}
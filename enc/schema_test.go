@@ -0,0 +1,147 @@
+package enc
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var nginxSchema = Schema{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"worker_processes": map[string]interface{}{"type": "integer", "minimum": 1, "maximum": 64},
+		"log_format":       map[string]interface{}{"enum": []interface{}{"main", "json"}},
+		"server_name":      map[string]interface{}{"type": "string", "pattern": "^[a-z0-9.-]+$"},
+		"upstreams": map[string]interface{}{
+			"type": "array",
+			"items": map[string]interface{}{
+				"type":                 "object",
+				"required":             []interface{}{"host"},
+				"properties":           map[string]interface{}{"host": map[string]interface{}{"type": "string"}, "port": map[string]interface{}{"type": "integer"}},
+				"additionalProperties": false,
+			},
+		},
+		"ssl": map[string]interface{}{"anyOf": []interface{}{
+			map[string]interface{}{"type": "boolean"},
+			map[string]interface{}{"type": "string", "minLength": 1},
+		}},
+	},
+	"required":             []interface{}{"worker_processes"},
+	"additionalProperties": false,
+}
+
+func TestSchemaValidate(t *testing.T) {
+	assert := assert.New(t)
+
+	valid := map[string]interface{}{
+		"worker_processes": 8,
+		"log_format":       "json",
+		"server_name":      "web.example.com",
+		"upstreams":        []interface{}{map[string]interface{}{"host": "app", "port": 8080}},
+		"ssl":              "web.pem",
+	}
+	assert.Empty(nginxSchema.Validate(valid, false))
+
+	// JSON files decode whole numbers as floats, which still count as integers
+	assert.Empty(nginxSchema.Validate(map[string]interface{}{"worker_processes": float64(8)}, false))
+
+	invalid := map[string]interface{}{
+		"worker_processes": 128,
+		"log_format":       "xml",
+		"server_name":      "Web Server",
+		"upstreams":        []interface{}{map[string]interface{}{"port": "http", "weight": 2}},
+		"ssl":              "",
+		"worker_procs":     4,
+	}
+	assert.Equal([]SchemaViolation{
+		{Path: "log_format", Message: `should be one of "main", "json"`},
+		{Path: "server_name", Message: "should match ^[a-z0-9.-]+$"},
+		{Path: "ssl", Message: "doesn't match any of the allowed schemas"},
+		{Path: "upstreams[0]", Message: "is missing required parameter host"},
+		{Path: "upstreams[0].port", Message: "should be integer, not string"},
+		{Path: "upstreams[0].weight", Message: "is not a known parameter"},
+		{Path: "worker_processes", Message: "should be at most 64"},
+		{Path: "worker_procs", Message: "is not a known parameter"},
+	}, nginxSchema.Validate(invalid, false))
+
+	// Partial bodies can leave required parameters to another nodegroup
+	assert.Empty(nginxSchema.Validate(map[string]interface{}{}, true))
+	assert.Equal([]SchemaViolation{{Message: "is missing required parameter worker_processes"}}, nginxSchema.Validate(map[string]interface{}{}, false))
+
+	// Secrets and, in nodegroups, references aren't checked
+	assert.Empty(nginxSchema.Validate(map[string]interface{}{"worker_processes": "ENC[secretbox,prod,AAAA]"}, false))
+	assert.Empty(nginxSchema.Validate(map[string]interface{}{"worker_processes": "%{param.workers}"}, true))
+	assert.NotEmpty(nginxSchema.Validate(map[string]interface{}{"worker_processes": "%{param.workers}"}, false))
+
+	assert.Empty(Schema{"oneOf": []interface{}{map[string]interface{}{"type": "integer"}, map[string]interface{}{"type": "string"}}}.Validate(4, false))
+	assert.Equal([]SchemaViolation{{Message: "should match exactly one of the allowed schemas, matches 2"}},
+		Schema{"oneOf": []interface{}{map[string]interface{}{"type": "number"}, map[string]interface{}{"type": "integer"}}}.Validate(4, false))
+	assert.Equal([]SchemaViolation{{Path: "debug", Message: "is not allowed"}},
+		Schema{"properties": map[string]interface{}{"debug": false}}.Validate(map[string]interface{}{"debug": true}, false))
+}
+
+func TestLoadClassSchemas(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "go-enc-schemas")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ioutil.WriteFile(filepath.Join(dir, "nginx.json"), []byte(`{"type": "object", "required": ["worker_processes"]}`), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "nginx__vhost.yaml"), []byte("type: object\nadditionalProperties: false\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "README.md"), []byte("Class schemas"), 0644)
+
+	schemas, err := LoadClassSchemas(dir)
+	assert.Nil(err)
+	assert.Equal(map[string]Schema{
+		"nginx":        {"type": "object", "required": []interface{}{"worker_processes"}},
+		"nginx::vhost": {"type": "object", "additionalProperties": false},
+	}, schemas)
+
+	ioutil.WriteFile(filepath.Join(dir, "broken.json"), []byte(`[1, 2]`), 0644)
+	_, err = LoadClassSchemas(dir)
+	assert.EqualError(err, "Schema broken.json should be an object")
+}
+
+func TestClassParametersCheckedOnWrite(t *testing.T) {
+	assert := assert.New(t)
+	c := newFixtureConfig()
+	c.ClassSchemas = map[string]Schema{"nginx": nginxSchema}
+	production := c.ENCs["production"]
+
+	_, err := production.SetClassParameter("website", "nginx", "worker_processes", "eight")
+	assert.EqualError(err, "Schema validation failed: Class nginx: worker_processes: should be integer, not string")
+	assert.Equal(8, production.Nodegroups["website"].Classes["nginx"].(map[string]interface{})["worker_processes"])
+
+	_, err = production.SetClassParameterPath("website", "nginx", "upstreams[0].hostname", "app")
+	assert.EqualError(err, "Schema validation failed: Class nginx: upstreams[0].hostname: is not a known parameter")
+	assert.NotContains(production.Nodegroups["website"].Classes["nginx"], "upstreams")
+
+	_, err = production.SetClassParameterPath("website", "nginx", "upstreams[0].host", "app")
+	assert.Nil(err)
+	_, err = production.AddClassParameter("website", "nginx", "log_format", "main")
+	assert.Nil(err)
+
+	// Classes without a schema take anything
+	_, err = production.SetClassParameter("globals", "ntp", "anything", true)
+	assert.Nil(err)
+}
+
+func TestValidateClassSchemas(t *testing.T) {
+	assert := assert.New(t)
+	c := newFixtureConfig()
+	production := c.ENCs["production"]
+	production.SetClassParameter("website_canary", "nginx", "log_format", "xml")
+	production.AddClass("database", "nginx")
+
+	c.ClassSchemas = map[string]Schema{"nginx": nginxSchema}
+	assert.Equal([]SchemaViolation{
+		{ENC: "production", Nodegroup: "website_canary", Class: "nginx", Path: "log_format", Message: `should be one of "main", "json"`},
+		{ENC: "production", Node: "db-0001", Class: "nginx", Message: "is missing required parameter worker_processes"},
+		{ENC: "production", Node: "web-0001", Class: "nginx", Path: "log_format", Message: `should be one of "main", "json"`},
+	}, c.ValidateClassSchemas())
+}
@@ -0,0 +1,294 @@
+package enc
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"golang.org/x/crypto/nacl/secretbox"
+)
+
+// SecretKeyExtension is the extension of key files in a key directory, the rest of the file name
+// being the key's name
+const SecretKeyExtension = ".key"
+
+var (
+	secretPattern  = regexp.MustCompile(`^ENC\[secretbox,([A-Za-z0-9_-]+),([A-Za-z0-9+/=]+)\]$`)
+	secretInText   = regexp.MustCompile(`ENC\[secretbox,[A-Za-z0-9_-]+,[A-Za-z0-9+/=]+\]`)
+	secretKeyNames = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+)
+
+// SecretKeys are the NaCl secretbox keys secret values are encrypted with, by name. Secrets are
+// written as ENC[secretbox,<key name>,<base64 nonce and ciphertext>].
+type SecretKeys map[string]*[32]byte
+
+// GenerateSecretKey returns the contents of a new key file
+func GenerateSecretKey() ([]byte, error) {
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return []byte{}, err
+	}
+
+	return []byte(base64.StdEncoding.EncodeToString(key) + "\n"), nil
+}
+
+// WriteSecretKey creates a new key file in dir, readable only by its owner
+func WriteSecretKey(dir string, name string) (string, error) {
+	if !secretKeyNames.MatchString(name) {
+		return "", fmt.Errorf("Key names can only have letters, numbers, - and _: %s", name)
+	}
+
+	contents, err := GenerateSecretKey()
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+
+	fileName := filepath.Join(dir, name+SecretKeyExtension)
+	file, err := os.OpenFile(fileName, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	_, err = file.Write(contents)
+	return fileName, err
+}
+
+// LoadSecretKeys reads every key file in dir
+func LoadSecretKeys(dir string) (SecretKeys, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*"+SecretKeyExtension))
+	if err != nil {
+		return SecretKeys{}, err
+	}
+
+	keys := make(SecretKeys, len(files))
+	for _, file := range files {
+		contents, err := ioutil.ReadFile(file)
+		if err != nil {
+			return SecretKeys{}, err
+		}
+
+		decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(contents)))
+		if err != nil || len(decoded) != 32 {
+			return SecretKeys{}, fmt.Errorf("Key file should hold 32 base64 encoded bytes: %s", file)
+		}
+
+		key := new([32]byte)
+		copy(key[:], decoded)
+		keys[strings.TrimSuffix(filepath.Base(file), SecretKeyExtension)] = key
+	}
+
+	return keys, nil
+}
+
+// IsSecret reports whether a value is an encrypted secret
+func IsSecret(value interface{}) bool {
+	str, ok := value.(string)
+	return ok && secretPattern.MatchString(str)
+}
+
+// Encrypt encrypts a value of any type with the named key
+func (keys SecretKeys) Encrypt(value interface{}, keyName string) (string, error) {
+	if IsSecret(value) {
+		return "", errors.New("Value is already encrypted")
+	}
+
+	key, ok := keys[keyName]
+	if !ok {
+		return "", fmt.Errorf("Secret key not found: %s", keyName)
+	}
+
+	plaintext, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+
+	var nonce [24]byte
+	if _, err := io.ReadFull(rand.Reader, nonce[:]); err != nil {
+		return "", err
+	}
+
+	sealed := secretbox.Seal(nonce[:], plaintext, &nonce, key)
+	return fmt.Sprintf("ENC[secretbox,%s,%s]", keyName, base64.StdEncoding.EncodeToString(sealed)), nil
+}
+
+// Decrypt returns the value a secret was encrypted from, and the name of the key it used
+func (keys SecretKeys) Decrypt(secret string) (interface{}, string, error) {
+	match := secretPattern.FindStringSubmatch(secret)
+	if match == nil {
+		return nil, "", errors.New("Value is not an encrypted secret")
+	}
+
+	keyName := match[1]
+	key, ok := keys[keyName]
+	if !ok {
+		return nil, keyName, fmt.Errorf("Secret key not found: %s", keyName)
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(match[2])
+	if err != nil || len(sealed) < 24+secretbox.Overhead {
+		return nil, keyName, fmt.Errorf("Secret is not valid: %s", secret)
+	}
+
+	var nonce [24]byte
+	copy(nonce[:], sealed[:24])
+	plaintext, ok := secretbox.Open(nil, sealed[24:], &nonce, key)
+	if !ok {
+		return nil, keyName, fmt.Errorf("Secret can't be decrypted with key %s", keyName)
+	}
+
+	value, err := ParseValue(string(plaintext), "json")
+	return value, keyName, err
+}
+
+// decryptValue returns a copy of value with the secrets in it decrypted
+func (keys SecretKeys) decryptValue(value interface{}) (interface{}, error) {
+	switch val := value.(type) {
+	case string:
+		if !IsSecret(val) {
+			return val, nil
+		}
+		decrypted, _, err := keys.Decrypt(val)
+		return decrypted, err
+	case map[string]interface{}:
+		decrypted := make(map[string]interface{}, len(val))
+		for _, key := range sortedKeys(val) {
+			item, err := keys.decryptValue(val[key])
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s", key, err)
+			}
+			decrypted[key] = item
+		}
+		return decrypted, nil
+	case []interface{}:
+		decrypted := make([]interface{}, 0, len(val))
+		for i, item := range val {
+			itemValue, err := keys.decryptValue(item)
+			if err != nil {
+				return nil, fmt.Errorf("[%d]: %s", i, err)
+			}
+			decrypted = append(decrypted, itemValue)
+		}
+		return decrypted, nil
+	default:
+		return value, nil
+	}
+}
+
+// decryptNodegroup returns a copy of nodegroup with the secrets in its parameters and class
+// parameters decrypted
+func (keys SecretKeys) decryptNodegroup(nodegroup *Nodegroup) (*Nodegroup, error) {
+	decrypted := *nodegroup
+	if nodegroup.Parameters != nil {
+		parameters, err := keys.decryptValue(nodegroup.Parameters)
+		if err != nil {
+			return &Nodegroup{}, fmt.Errorf("Decrypting parameter %s", err)
+		}
+		decrypted.Parameters = parameters.(map[string]interface{})
+	}
+
+	if nodegroup.Classes != nil {
+		classes, err := keys.decryptValue(nodegroup.Classes)
+		if err != nil {
+			return &Nodegroup{}, fmt.Errorf("Decrypting class %s", err)
+		}
+		decrypted.Classes = classes.(map[string]interface{})
+	}
+
+	return &decrypted, nil
+}
+
+// EncryptParameterPath encrypts the parameter of a nodegroup at path, in place
+func (enc *ENC) EncryptParameterPath(nodegroupName string, path string, keys SecretKeys, keyName string) (*Nodegroup, error) {
+	return enc.updateParameters(nodegroupName, func(parameters map[string]interface{}) error {
+		return encryptPath(parameters, path, keys, keyName)
+	})
+}
+
+// EncryptClassParameterPath encrypts the parameter of a class on a nodegroup at path, in place
+func (enc *ENC) EncryptClassParameterPath(nodegroupName string, class string, path string, keys SecretKeys, keyName string) (*Nodegroup, error) {
+	return enc.updateClassParameters(nodegroupName, class, func(ngClass map[string]interface{}) error {
+		return encryptPath(ngClass, path, keys, keyName)
+	})
+}
+
+func encryptPath(data map[string]interface{}, path string, keys SecretKeys, keyName string) error {
+	value, err := GetPath(data, path)
+	if err != nil {
+		return err
+	}
+
+	secret, err := keys.Encrypt(value, keyName)
+	if err != nil {
+		return err
+	}
+
+	return SetPath(data, path, secret)
+}
+
+// RotateSecrets re-encrypts the secrets in every nodegroup of the ENC with the key toKey. With
+// fromKey, only the secrets encrypted with that key are. It returns how many were re-encrypted.
+func (enc *ENC) RotateSecrets(keys SecretKeys, fromKey string, toKey string) (int, error) {
+	if _, ok := keys[toKey]; !ok {
+		return 0, fmt.Errorf("Secret key not found: %s", toKey)
+	}
+
+	rotated := 0
+	var rotate func(value interface{}) (interface{}, error)
+	rotate = func(value interface{}) (interface{}, error) {
+		switch val := value.(type) {
+		case string:
+			if !IsSecret(val) || (fromKey != "" && secretPattern.FindStringSubmatch(val)[1] != fromKey) {
+				return val, nil
+			}
+
+			decrypted, _, err := keys.Decrypt(val)
+			if err != nil {
+				return nil, err
+			}
+			rotated++
+			return keys.Encrypt(decrypted, toKey)
+		case map[string]interface{}:
+			for key, item := range val {
+				itemValue, err := rotate(item)
+				if err != nil {
+					return nil, err
+				}
+				val[key] = itemValue
+			}
+		case []interface{}:
+			for i, item := range val {
+				itemValue, err := rotate(item)
+				if err != nil {
+					return nil, err
+				}
+				val[i] = itemValue
+			}
+		}
+		return value, nil
+	}
+
+	for _, name := range enc.NodegroupNames() {
+		nodegroup := enc.Nodegroups[name]
+		if _, err := rotate(nodegroup.Parameters); err != nil {
+			return rotated, fmt.Errorf("Nodegroup %s: %s", name, err)
+		}
+		if _, err := rotate(nodegroup.Classes); err != nil {
+			return rotated, fmt.Errorf("Nodegroup %s: %s", name, err)
+		}
+	}
+
+	return rotated, nil
+}
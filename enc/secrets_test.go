@@ -0,0 +1,159 @@
+package enc
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestSecretKeys(t *testing.T, names ...string) (SecretKeys, string, func()) {
+	dir, err := ioutil.TempDir("", "go-enc-keys")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range names {
+		if _, err := WriteSecretKey(dir, name); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	keys, err := LoadSecretKeys(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return keys, dir, func() { os.RemoveAll(dir) }
+}
+
+func TestSecretKeyFiles(t *testing.T) {
+	assert := assert.New(t)
+	keys, dir, cleanup := newTestSecretKeys(t, "prod", "staging-2")
+	defer cleanup()
+
+	assert.Len(keys, 2)
+	assert.NotNil(keys["prod"])
+	assert.NotEqual(*keys["prod"], *keys["staging-2"])
+
+	info, err := os.Stat(filepath.Join(dir, "prod.key"))
+	assert.Nil(err)
+	assert.Equal(os.FileMode(0600), info.Mode().Perm())
+
+	_, err = WriteSecretKey(dir, "prod")
+	assert.NotNil(err)
+	_, err = WriteSecretKey(dir, "../escape")
+	assert.NotNil(err)
+
+	ioutil.WriteFile(filepath.Join(dir, "short.key"), []byte("c2hvcnQ=\n"), 0600)
+	_, err = LoadSecretKeys(dir)
+	assert.NotNil(err)
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	assert := assert.New(t)
+	keys, _, cleanup := newTestSecretKeys(t, "prod", "other")
+	defer cleanup()
+
+	for _, value := range []interface{}{"hunter2", 5432, true, map[string]interface{}{"user": "app", "ports": []interface{}{1, 2}}} {
+		secret, err := keys.Encrypt(value, "prod")
+		assert.Nil(err)
+		assert.True(IsSecret(secret), secret)
+		assert.True(strings.HasPrefix(secret, "ENC[secretbox,prod,"), secret)
+
+		decrypted, keyName, err := keys.Decrypt(secret)
+		assert.Nil(err)
+		assert.Equal("prod", keyName)
+		assert.Equal(value, decrypted)
+	}
+
+	first, _ := keys.Encrypt("hunter2", "prod")
+	second, _ := keys.Encrypt("hunter2", "prod")
+	assert.NotEqual(first, second)
+
+	_, err := keys.Encrypt(first, "prod")
+	assert.EqualError(err, "Value is already encrypted")
+	_, err = keys.Encrypt("hunter2", "missing")
+	assert.EqualError(err, "Secret key not found: missing")
+
+	// The wrong key, or a tampered secret, doesn't decrypt
+	wrongKey := strings.Replace(first, "ENC[secretbox,prod,", "ENC[secretbox,other,", 1)
+	_, _, err = keys.Decrypt(wrongKey)
+	assert.EqualError(err, "Secret can't be decrypted with key other")
+
+	_, _, err = SecretKeys{}.Decrypt(first)
+	assert.EqualError(err, "Secret key not found: prod")
+
+	assert.False(IsSecret("hunter2"))
+	assert.False(IsSecret(5))
+}
+
+func TestSecretsDecryptWhenClassifying(t *testing.T) {
+	assert := assert.New(t)
+	keys, _, cleanup := newTestSecretKeys(t, "prod")
+	defer cleanup()
+
+	c := newFixtureConfig()
+	production := c.ENCs["production"]
+
+	secret, err := keys.Encrypt("hunter2", "prod")
+	assert.Nil(err)
+	production.SetParameterPath("globals", "db.password", secret)
+	production.SetParameterPath("website", "db.dsn", "postgres://app:%{param.db.password}@db")
+	production.EncryptClassParameterPath("website", "nginx", "worker_processes", keys, "prod")
+
+	// Without keys, secrets are left as they are
+	node, err := production.GetNode("web-0002")
+	assert.Nil(err)
+	assert.Equal(secret, node.Parameters["db"].(map[string]interface{})["password"])
+
+	c.SecretKeys = keys
+	node, err = production.GetNode("web-0002")
+	assert.Nil(err)
+	assert.Equal(map[string]interface{}{"password": "hunter2", "dsn": "postgres://app:hunter2@db"}, node.Parameters["db"])
+	assert.Equal(8, node.Classes["nginx"].(map[string]interface{})["worker_processes"])
+
+	// The ENC itself keeps the ciphertext
+	assert.Equal(secret, production.Nodegroups["globals"].Parameters["db"].(map[string]interface{})["password"])
+	assert.True(IsSecret(production.Nodegroups["website"].Classes["nginx"].(map[string]interface{})["worker_processes"]))
+
+	c.SecretKeys = SecretKeys{}
+	_, err = production.GetNode("web-0002")
+	assert.EqualError(err, "Decrypting parameter db: password: Secret key not found: prod")
+}
+
+func TestRotateSecrets(t *testing.T) {
+	assert := assert.New(t)
+	keys, _, cleanup := newTestSecretKeys(t, "old", "new", "other")
+	defer cleanup()
+
+	c := newFixtureConfig()
+	production := c.ENCs["production"]
+
+	oldSecret, _ := keys.Encrypt("hunter2", "old")
+	otherSecret, _ := keys.Encrypt("s3cret", "other")
+	production.SetParameterPath("globals", "db.password", oldSecret)
+	production.SetParameterPath("website", "api_tokens", []interface{}{otherSecret, "public"})
+
+	rotated, err := production.RotateSecrets(keys, "old", "new")
+	assert.Nil(err)
+	assert.Equal(1, rotated)
+
+	password, _ := production.GetParameterPath("globals", "db.password")
+	assert.True(strings.HasPrefix(password.(string), "ENC[secretbox,new,"))
+	decrypted, _, _ := keys.Decrypt(password.(string))
+	assert.Equal("hunter2", decrypted)
+
+	token, _ := production.GetParameterPath("website", "api_tokens[0]")
+	assert.Equal(otherSecret, token)
+
+	rotated, err = production.RotateSecrets(keys, "", "new")
+	assert.Nil(err)
+	assert.Equal(2, rotated)
+
+	_, err = production.RotateSecrets(keys, "", "missing")
+	assert.NotNil(err)
+}
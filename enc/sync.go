@@ -0,0 +1,134 @@
+package enc
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// NodeInventory lists the machines that really exist, e.g. from a PuppetDB nodes export
+type NodeInventory struct {
+	Active      []string
+	Deactivated []string
+}
+
+// NodeSync compares the nodes classified by the ENCs with a NodeInventory
+type NodeSync struct {
+	// Missing nodes are classified but not in the inventory at all
+	Missing []string `json:"missing" yaml:"missing"`
+	// Deactivated nodes are classified but deactivated or expired in the inventory
+	Deactivated []string `json:"deactivated" yaml:"deactivated"`
+	// Unclassified nodes are active in the inventory but not in any nodegroup
+	Unclassified []string `json:"unclassified" yaml:"unclassified"`
+}
+
+// ParseNodeInventory reads either a PuppetDB nodes export, i.e. a JSON list of objects with a
+// certname and optional deactivated and expired timestamps, a JSON list of certnames, or a
+// plain list with a certname per line where blank lines and # comments are ignored
+func ParseNodeInventory(data []byte) (*NodeInventory, error) {
+	inventory := &NodeInventory{Active: []string{}, Deactivated: []string{}}
+
+	trimmed := bytes.TrimSpace(data)
+	if !bytes.HasPrefix(trimmed, []byte("[")) {
+		scanner := bufio.NewScanner(bytes.NewReader(trimmed))
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line != "" && !strings.HasPrefix(line, "#") {
+				inventory.Active = append(inventory.Active, line)
+			}
+		}
+
+		return inventory, scanner.Err()
+	}
+
+	var raw []interface{}
+	if err := json.Unmarshal(trimmed, &raw); err != nil {
+		return inventory, err
+	}
+
+	for _, item := range raw {
+		switch itemVal := item.(type) {
+		case string:
+			inventory.Active = append(inventory.Active, itemVal)
+		case map[string]interface{}:
+			certname, _ := itemVal["certname"].(string)
+			if certname == "" {
+				return inventory, fmt.Errorf("PuppetDB node without a certname: %v", itemVal)
+			}
+
+			if itemVal["deactivated"] != nil || itemVal["expired"] != nil {
+				inventory.Deactivated = append(inventory.Deactivated, certname)
+			} else {
+				inventory.Active = append(inventory.Active, certname)
+			}
+		default:
+			return inventory, fmt.Errorf("Unrecognised node in inventory: %v", item)
+		}
+	}
+
+	return inventory, nil
+}
+
+// SyncNodes reports the differences between the nodes of every ENC and an inventory
+func (c *Config) SyncNodes(inventory *NodeInventory) *NodeSync {
+	sync := &NodeSync{Missing: []string{}, Deactivated: []string{}, Unclassified: []string{}}
+
+	active := make(map[string]bool, len(inventory.Active))
+	for _, node := range inventory.Active {
+		active[node] = true
+	}
+
+	deactivated := make(map[string]bool, len(inventory.Deactivated))
+	for _, node := range inventory.Deactivated {
+		deactivated[node] = true
+	}
+
+	classified := make(map[string]bool)
+	for _, encName := range c.ENCNames() {
+		for _, node := range c.ENCs[encName].NodeNames() {
+			classified[node] = true
+		}
+	}
+
+	for node := range classified {
+		switch {
+		case active[node]:
+		case deactivated[node]:
+			sync.Deactivated = append(sync.Deactivated, node)
+		default:
+			sync.Missing = append(sync.Missing, node)
+		}
+	}
+
+	for node := range active {
+		if !classified[node] {
+			sync.Unclassified = append(sync.Unclassified, node)
+		}
+	}
+
+	sort.Strings(sync.Missing)
+	sort.Strings(sync.Deactivated)
+	sort.Strings(sync.Unclassified)
+
+	return sync
+}
+
+// RemoveNodeEverywhere removes a node from every nodegroup of every ENC, returning the
+// nodegroups it was removed from as name@cluster
+func (c *Config) RemoveNodeEverywhere(nodeName string) ([]string, error) {
+	removed := make([]string, 0)
+	for _, encName := range c.ENCNames() {
+		currentEnc := c.ENCs[encName]
+		for _, nodegroup := range currentEnc.NodeNodegroups(nodeName) {
+			if _, err := currentEnc.RemoveNode(nodegroup, nodeName); err != nil {
+				return removed, err
+			}
+			removed = append(removed, QualifyNodegroup(nodegroup, encName))
+		}
+	}
+
+	return removed, nil
+}
@@ -0,0 +1,60 @@
+package enc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseNodeInventory(t *testing.T) {
+	assert := assert.New(t)
+
+	inventory, err := ParseNodeInventory([]byte(`[
+  {"certname": "web-0001", "deactivated": null, "expired": null},
+  {"certname": "web-0002", "deactivated": "2018-02-01T10:00:00.000Z", "expired": null},
+  {"certname": "db-0001", "deactivated": null, "expired": "2018-02-01T10:00:00.000Z"}
+]`))
+	assert.Nil(err)
+	assert.Equal(&NodeInventory{Active: []string{"web-0001"}, Deactivated: []string{"web-0002", "db-0001"}}, inventory)
+
+	inventory, err = ParseNodeInventory([]byte(`["web-0001", "web-0002"]`))
+	assert.Nil(err)
+	assert.Equal([]string{"web-0001", "web-0002"}, inventory.Active)
+
+	inventory, err = ParseNodeInventory([]byte("# from puppet cert list\nweb-0001\n\n  web-0002\n"))
+	assert.Nil(err)
+	assert.Equal(&NodeInventory{Active: []string{"web-0001", "web-0002"}, Deactivated: []string{}}, inventory)
+
+	_, err = ParseNodeInventory([]byte(`[{"name": "web-0001"}]`))
+	assert.NotNil(err)
+
+	_, err = ParseNodeInventory([]byte(`[1, 2]`))
+	assert.NotNil(err)
+}
+
+func TestSyncNodes(t *testing.T) {
+	assert := assert.New(t)
+	c := newFixtureConfig()
+
+	sync := c.SyncNodes(&NodeInventory{
+		Active:      []string{"web-0001", "web-0101", "web-0003"},
+		Deactivated: []string{"db-0001"},
+	})
+	assert.Equal(&NodeSync{
+		Missing:      []string{"web-0002"},
+		Deactivated:  []string{"db-0001"},
+		Unclassified: []string{"web-0003"},
+	}, sync)
+}
+
+func TestRemoveNodeEverywhere(t *testing.T) {
+	assert := assert.New(t)
+	c := newFixtureConfig()
+
+	removed, err := c.RemoveNodeEverywhere("web-0001")
+	assert.Nil(err)
+	assert.Equal([]string{"website@production", "website_canary@production"}, removed)
+	assert.Equal([]string{"web-0002"}, c.ENCs["production"].Nodegroups["website"].Nodes)
+	assert.Equal([]string{}, c.ENCs["production"].Nodegroups["website_canary"].Nodes)
+	assert.Equal([]string{"db-0001", "web-0002", "web-0101"}, c.SyncNodes(&NodeInventory{}).Missing)
+}
@@ -0,0 +1,107 @@
+package enc
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// TerraformQuery answers a query from Terraform's external data source. The query has either a
+// "node" or a "nodegroup" key, and optionally "enc". A node is resolved with GetNode in that ENC,
+// or searched for in every ENC using conflictRule. A nodegroup needs the ENC, either as "enc" or
+// as name@cluster, and is resolved with its inherited parameters. The result is the flattened
+// parameters, as Terraform only accepts a flat map of strings.
+func (c *Config) TerraformQuery(query map[string]string, conflictRule string) (map[string]string, error) {
+	var (
+		nodegroup *Nodegroup
+		err       error
+	)
+
+	node, nodegroupName, encName := query["node"], query["nodegroup"], query["enc"]
+	for key := range query {
+		switch key {
+		case "node", "nodegroup", "enc":
+		default:
+			return map[string]string{}, fmt.Errorf("Unrecognised query key, expecting: node|nodegroup|enc: %s", key)
+		}
+	}
+
+	switch {
+	case node != "" && nodegroupName != "":
+		return map[string]string{}, fmt.Errorf("Query needs either a node or a nodegroup, not both")
+	case node != "" && encName != "":
+		var currentEnc *ENC
+		if currentEnc, err = c.GetENC(encName); err != nil {
+			return map[string]string{}, err
+		}
+		nodegroup, err = currentEnc.GetNode(node)
+	case node != "":
+		var lookup *NodeLookup
+		if lookup, err = c.LookupNode(node, conflictRule); err == nil {
+			nodegroup = lookup.Nodegroup
+		}
+	case nodegroupName != "":
+		if strings.Contains(nodegroupName, "@") {
+			encName = strings.SplitN(nodegroupName, "@", 2)[1]
+		} else if encName == "" {
+			return map[string]string{}, fmt.Errorf("Query needs an enc for nodegroup %s, or use name@cluster", nodegroupName)
+		}
+
+		var currentEnc *ENC
+		if currentEnc, err = c.GetENC(encName); err != nil {
+			return map[string]string{}, err
+		}
+		nodegroup, err = currentEnc.GetInheritedNodegroup(QualifyNodegroup(nodegroupName, encName))
+	default:
+		return map[string]string{}, fmt.Errorf("Query needs a node or a nodegroup")
+	}
+
+	if err != nil {
+		return map[string]string{}, err
+	}
+
+	return FlattenParameters(nodegroup.Parameters)
+}
+
+// FlattenParameters flattens nested parameters into dotted keys, e.g. {"app": {"tier": "web"}}
+// becomes {"app.tier": "web"}. Strings are kept as they are and every other value, including
+// lists, is encoded as JSON.
+func FlattenParameters(parameters map[string]interface{}) (map[string]string, error) {
+	flat := make(map[string]string)
+	return flat, flattenParameters(flat, "", parameters)
+}
+
+func flattenParameters(flat map[string]string, prefix string, parameters map[string]interface{}) error {
+	keys := make([]string, 0, len(parameters))
+	for key := range parameters {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		flatKey := prefix + key
+		if _, ok := flat[flatKey]; ok {
+			return fmt.Errorf("Parameter %s is set both nested and flat", flatKey)
+		}
+
+		switch value := parameters[key].(type) {
+		case map[string]interface{}:
+			if err := flattenParameters(flat, flatKey+".", value); err != nil {
+				return err
+			}
+		case string:
+			flat[flatKey] = value
+		case nil:
+			flat[flatKey] = ""
+		default:
+			encoded, err := json.Marshal(value)
+			if err != nil {
+				return err
+			}
+			flat[flatKey] = string(encoded)
+		}
+	}
+
+	return nil
+}
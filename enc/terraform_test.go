@@ -0,0 +1,73 @@
+package enc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlattenParameters(t *testing.T) {
+	assert := assert.New(t)
+
+	flat, err := FlattenParameters(map[string]interface{}{
+		"datacenter": "dub1",
+		"app": map[string]interface{}{
+			"tier":    "web",
+			"workers": 8,
+			"limits":  map[string]interface{}{"memory": "2G"},
+		},
+		"dns_servers": []interface{}{"10.0.0.1", "10.0.0.2"},
+		"debug":       false,
+		"unset":       nil,
+	})
+	assert.Nil(err)
+	assert.Equal(map[string]string{
+		"datacenter":        "dub1",
+		"app.tier":          "web",
+		"app.workers":       "8",
+		"app.limits.memory": "2G",
+		"dns_servers":       `["10.0.0.1","10.0.0.2"]`,
+		"debug":             "false",
+		"unset":             "",
+	}, flat)
+
+	_, err = FlattenParameters(map[string]interface{}{
+		"app":      map[string]interface{}{"tier": "web"},
+		"app.tier": "db",
+	})
+	assert.NotNil(err)
+}
+
+func TestTerraformQuery(t *testing.T) {
+	assert := assert.New(t)
+	c := newFixtureConfig()
+	c.ENCs["production"].AddParameter("website", "app", map[string]interface{}{"tier": "web"})
+
+	result, err := c.TerraformQuery(map[string]string{"node": "web-0002"}, NodeConflictError)
+	assert.Nil(err)
+	assert.Equal(map[string]string{"datacenter": "dub1", "app.tier": "web"}, result)
+
+	result, err = c.TerraformQuery(map[string]string{"node": "web-0101", "enc": "staging"}, NodeConflictError)
+	assert.Nil(err)
+	assert.Equal(map[string]string{"datacenter": "dub2", "app.tier": "web"}, result)
+
+	result, err = c.TerraformQuery(map[string]string{"nodegroup": "website_canary", "enc": "production"}, NodeConflictError)
+	assert.Nil(err)
+	assert.Equal(map[string]string{"datacenter": "dub1", "app.tier": "web"}, result)
+
+	result, err = c.TerraformQuery(map[string]string{"nodegroup": "globals@production"}, NodeConflictError)
+	assert.Nil(err)
+	assert.Equal(map[string]string{"datacenter": "dub1"}, result)
+
+	for _, query := range []map[string]string{
+		{},
+		{"nodegroup": "globals"},
+		{"node": "web-0001", "nodegroup": "globals@production"},
+		{"node": "web-0001", "role": "web"},
+		{"node": "web-9999"},
+		{"nodegroup": "nope@production"},
+	} {
+		_, err = c.TerraformQuery(query, NodeConflictError)
+		assert.NotNil(err, "%v", query)
+	}
+}
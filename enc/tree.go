@@ -0,0 +1,115 @@
+package enc
+
+import (
+	"sort"
+	"strings"
+)
+
+// TreeNode is a nodegroup in the parent/child hierarchy spanning every ENC in a config
+type TreeNode struct {
+	Name     string
+	ENC      string
+	Nodes    []string
+	CrossENC bool
+	Missing  bool
+	Children []*TreeNode
+}
+
+// ID returns the fully qualified name of the nodegroup, name@cluster
+func (t *TreeNode) ID() string {
+	return t.Name + "@" + t.ENC
+}
+
+// NodegroupTree builds the forest of nodegroups across all ENCs, following Nodegroup.Parent.
+// Roots are nodegroups without a parent. A parent that doesn't exist is included as a Missing
+// root so its children still appear, and CrossENC marks children whose parent is in another ENC.
+func (c *Config) NodegroupTree() []*TreeNode {
+	treeNodes := make(map[string]*TreeNode)
+	parents := make(map[string]string)
+	ids := make([]string, 0)
+
+	for _, encName := range c.ENCNames() {
+		currentEnc := c.ENCs[encName]
+		for _, name := range currentEnc.NodegroupNames() {
+			treeNode := &TreeNode{
+				Name:     name,
+				ENC:      encName,
+				Nodes:    currentEnc.Nodegroups[name].Nodes,
+				Children: []*TreeNode{},
+			}
+			treeNodes[treeNode.ID()] = treeNode
+			ids = append(ids, treeNode.ID())
+
+			if parent := currentEnc.Nodegroups[name].Parent; parent != "" {
+				parents[treeNode.ID()] = QualifyNodegroup(parent, encName)
+			}
+		}
+	}
+
+	roots := make([]*TreeNode, 0)
+	for _, id := range ids {
+		treeNode := treeNodes[id]
+
+		parentID, hasParent := parents[id]
+		if !hasParent {
+			roots = append(roots, treeNode)
+			continue
+		}
+
+		parentNode, ok := treeNodes[parentID]
+		if !ok {
+			parentSplit := strings.SplitN(parentID, "@", 2)
+			parentNode = &TreeNode{Name: parentSplit[0], ENC: parentSplit[1], Missing: true, Children: []*TreeNode{}}
+			treeNodes[parentID] = parentNode
+			roots = append(roots, parentNode)
+		}
+
+		treeNode.CrossENC = parentNode.ENC != treeNode.ENC
+		parentNode.Children = append(parentNode.Children, treeNode)
+	}
+
+	// Nodegroups whose parents form a loop are never reached from a root, so list them as roots
+	reached := make(map[string]bool)
+	for _, root := range roots {
+		markReached(root, reached)
+	}
+	for _, id := range ids {
+		if !reached[id] {
+			roots = append(roots, treeNodes[id])
+			markReached(treeNodes[id], reached)
+		}
+	}
+
+	for _, treeNode := range treeNodes {
+		sort.Sort(treeNodesByID(treeNode.Children))
+	}
+	sort.Sort(treeNodesByID(roots))
+
+	return roots
+}
+
+// QualifyNodegroup adds the "@cluster" suffix to a nodegroup name if it doesn't already have one
+func QualifyNodegroup(nodegroupName string, encName string) string {
+	if strings.Contains(nodegroupName, "@") {
+		return nodegroupName
+	}
+
+	return nodegroupName + "@" + encName
+}
+
+func markReached(treeNode *TreeNode, reached map[string]bool) {
+	if reached[treeNode.ID()] {
+		return
+	}
+
+	reached[treeNode.ID()] = true
+	for _, child := range treeNode.Children {
+		markReached(child, reached)
+	}
+}
+
+type treeNodesByID []*TreeNode
+
+func (t treeNodesByID) Len() int           { return len(t) }
+func (t treeNodesByID) Swap(i, j int)      { t[i], t[j] = t[j], t[i] }
+func (t treeNodesByID) Less(i, j int) bool { return t[i].ID() < t[j].ID() }
@@ -0,0 +1,59 @@
+package enc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNodegroupTree(t *testing.T) {
+	assert := assert.New(t)
+	c := newFixtureConfig()
+	c.ENCs["staging"].AddNodegroup("lost", "missing@production", map[string]interface{}{}, []string{}, map[string]interface{}{})
+
+	roots := c.NodegroupTree()
+	assert.Len(roots, 2)
+
+	globals := roots[0]
+	assert.Equal("globals@production", globals.ID())
+	assert.False(globals.Missing)
+	assert.Len(globals.Children, 2)
+	assert.Equal("database@production", globals.Children[0].ID())
+	assert.Equal([]string{"db-0001"}, globals.Children[0].Nodes)
+
+	website := globals.Children[1]
+	assert.Equal("website@production", website.ID())
+	assert.Len(website.Children, 2)
+	assert.Equal("staging_web@staging", website.Children[0].ID())
+	assert.True(website.Children[0].CrossENC)
+	assert.Equal("website_canary@production", website.Children[1].ID())
+	assert.False(website.Children[1].CrossENC)
+
+	missing := roots[1]
+	assert.Equal("missing@production", missing.ID())
+	assert.True(missing.Missing)
+	assert.Len(missing.Children, 1)
+	assert.True(missing.Children[0].CrossENC)
+}
+
+func TestNodegroupTreeCycle(t *testing.T) {
+	assert := assert.New(t)
+	c := newFixtureConfig()
+	production := c.ENCs["production"]
+	production.AddNodegroup("loop_a", "loop_b@production", map[string]interface{}{}, []string{}, map[string]interface{}{})
+	production.AddNodegroup("loop_b", "loop_a@production", map[string]interface{}{}, []string{}, map[string]interface{}{})
+
+	ids := []string{}
+	for _, root := range c.NodegroupTree() {
+		ids = append(ids, root.ID())
+	}
+
+	assert.Equal([]string{"globals@production", "loop_a@production"}, ids)
+}
+
+func TestQualifyNodegroup(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("website@production", QualifyNodegroup("website", "production"))
+	assert.Equal("website@staging", QualifyNodegroup("website@staging", "production"))
+}
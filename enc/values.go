@@ -0,0 +1,92 @@
+package enc
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// ValueTypes lists how ParseValue can read a value given as text, "auto" guessing from its contents
+var ValueTypes = []string{"string", "auto", "int", "float", "bool", "json", "yaml"}
+
+// ParseValue reads a value given as text, e.g. on the command line, as one of the ValueTypes.
+// Maps and lists come back as map[string]interface{} and []interface{}, whole numbers as int.
+func ParseValue(value string, valueType string) (interface{}, error) {
+	switch valueType {
+	case "string":
+		return value, nil
+	case "auto":
+		return autoValue(value), nil
+	case "int":
+		parsed, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("Not an int: %s", value)
+		}
+		return parsed, nil
+	case "float":
+		parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			return nil, fmt.Errorf("Not a float: %s", value)
+		}
+		return parsed, nil
+	case "bool":
+		parsed, err := strconv.ParseBool(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("Not a bool: %s", value)
+		}
+		return parsed, nil
+	case "json":
+		var parsed interface{}
+		if err := json.Unmarshal([]byte(value), &parsed); err != nil {
+			return nil, fmt.Errorf("Not valid JSON: %s", err)
+		}
+		// JSON is YAML, which reads whole numbers as ints like the ENC files do
+		return yamlValue(value)
+	case "yaml":
+		return yamlValue(value)
+	default:
+		return nil, fmt.Errorf("Unrecognised value type, expecting: %s: %s", strings.Join(ValueTypes, "|"), valueType)
+	}
+}
+
+// autoValue reads numbers, true/false, null and JSON maps and lists, leaving anything else a string
+func autoValue(value string) interface{} {
+	trimmed := strings.TrimSpace(value)
+
+	if parsed, err := strconv.Atoi(trimmed); err == nil {
+		return parsed
+	}
+	if parsed, err := strconv.ParseFloat(trimmed, 64); err == nil && !math.IsInf(parsed, 0) && !math.IsNaN(parsed) {
+		return parsed
+	}
+
+	switch trimmed {
+	case "true":
+		return true
+	case "false":
+		return false
+	case "null":
+		return nil
+	}
+
+	if strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[") {
+		if parsed, err := ParseValue(trimmed, "json"); err == nil {
+			return parsed
+		}
+	}
+
+	return value
+}
+
+func yamlValue(value string) (interface{}, error) {
+	var parsed interface{}
+	if err := yaml.Unmarshal([]byte(value), &parsed); err != nil {
+		return nil, fmt.Errorf("Not valid YAML: %s", err)
+	}
+
+	return stringifyYAMLMapKeys(parsed), nil
+}
@@ -0,0 +1,48 @@
+package enc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseValue(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		value     string
+		valueType string
+		want      interface{}
+	}{
+		{"4", "string", "4"},
+		{"4", "int", 4},
+		{" 4 ", "int", 4},
+		{"1.5", "float", 1.5},
+		{"true", "bool", true},
+		{"0", "bool", false},
+		{`{"port": 8080, "hosts": ["a", "b"]}`, "json", map[string]interface{}{"port": 8080, "hosts": []interface{}{"a", "b"}}},
+		{"[1, 2.5]", "json", []interface{}{1, 2.5}},
+		{"port: 8080\nssl: true", "yaml", map[string]interface{}{"port": 8080, "ssl": true}},
+		{"- a\n- b", "yaml", []interface{}{"a", "b"}},
+		{"4", "auto", 4},
+		{"-1.5", "auto", -1.5},
+		{"false", "auto", false},
+		{"null", "auto", nil},
+		{`["a", 1]`, "auto", []interface{}{"a", 1}},
+		{"[not json", "auto", "[not json"},
+		{"inf", "auto", "inf"},
+		{"1234567 ", "auto", 1234567},
+		{"web-0001", "auto", "web-0001"},
+	}
+
+	for _, test := range tests {
+		got, err := ParseValue(test.value, test.valueType)
+		assert.Nil(err, test.value)
+		assert.Equal(test.want, got, test.value)
+	}
+
+	for _, bad := range [][]string{{"four", "int"}, {"1.5", "int"}, {"x", "float"}, {"yes", "bool"}, {"{", "json"}, {"port: [", "yaml"}, {"4", "number"}} {
+		_, err := ParseValue(bad[0], bad[1])
+		assert.NotNil(err, bad[0])
+	}
+}
@@ -0,0 +1,588 @@
+package enc
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strings"
+
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// yamlDocument keeps the parsed form of a YAML ENC file so it can be written back with its
+// comments, key order, quoting and indentation intact, only changing what changed
+type yamlDocument struct {
+	root             *yamlv3.Node
+	blankBefore      map[*yamlv3.Node]bool
+	indent           int
+	compactSequences bool
+	documentStart    bool
+	// preamble holds the comments above "---", which yaml.v3 attaches to the first key
+	preamble string
+	// loaded is the value of each node as the ENC read it with yaml.v2, or as last written. yaml.v3
+	// reads scalars like yes and 2020-01-01 differently, so decoding the nodes again would make
+	// untouched values look changed.
+	loaded map[*yamlv3.Node]interface{}
+}
+
+// parseYAMLDocument returns nil when the file can't be preserved, e.g. it uses anchors or isn't
+// a mapping of nodegroups, so it's written out from scratch instead
+func parseYAMLDocument(data []byte) *yamlDocument {
+	var root yamlv3.Node
+	if err := yamlv3.Unmarshal(data, &root); err != nil {
+		return nil
+	}
+
+	if root.Kind != yamlv3.DocumentNode || len(root.Content) != 1 || root.Content[0].Kind != yamlv3.MappingNode || len(root.Content[0].Content) == 0 || usesAnchors(&root) {
+		return nil
+	}
+
+	// Decoded again rather than shared with the ENC, which changes its values in place
+	rawEnc, err := DecodeENC("yaml", data)
+	if err != nil {
+		return nil
+	}
+
+	doc := &yamlDocument{
+		root:             &root,
+		blankBefore:      make(map[*yamlv3.Node]bool),
+		indent:           2,
+		compactSequences: true,
+		loaded:           make(map[*yamlv3.Node]interface{}),
+	}
+	doc.recordLoaded(root.Content[0], rawEnc)
+
+	lines := strings.Split(string(data), "\n")
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		if doc.documentStart = trimmed == "---"; doc.documentStart && i > 0 {
+			doc.preamble = strings.Join(lines[:i], "\n")
+			firstKey := root.Content[0].Content[0]
+			for _, node := range []*yamlv3.Node{&root, firstKey} {
+				if strings.HasPrefix(node.HeadComment, doc.preamble) {
+					node.HeadComment = strings.TrimLeft(strings.TrimPrefix(node.HeadComment, doc.preamble), "\n")
+					break
+				}
+			}
+		}
+		break
+	}
+
+	// Take the indentation from the first nested mapping and sequence in the file
+	foundIndent, foundSequence := false, false
+	walkYAMLPairs(root.Content[0], func(key *yamlv3.Node, value *yamlv3.Node) {
+		if value.Style&yamlv3.FlowStyle != 0 || len(value.Content) == 0 {
+			return
+		}
+
+		switch {
+		case value.Kind == yamlv3.MappingNode && !foundIndent:
+			foundIndent = true
+			doc.indent = value.Content[0].Column - key.Column
+		case value.Kind == yamlv3.SequenceNode && !foundSequence:
+			foundSequence = true
+			doc.compactSequences = value.Column == key.Column
+		}
+	})
+	if doc.indent < 1 {
+		doc.indent = 2
+	}
+
+	markBlankLines(root.Content[0], lines, doc.blankBefore)
+
+	return doc
+}
+
+// recordLoaded pairs node and its children with the values the ENC read for them
+func (doc *yamlDocument) recordLoaded(node *yamlv3.Node, value interface{}) {
+	doc.loaded[node] = value
+
+	switch val := value.(type) {
+	case map[string]interface{}:
+		if node.Kind != yamlv3.MappingNode {
+			return
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if child, ok := val[node.Content[i].Value]; ok {
+				doc.recordLoaded(node.Content[i+1], child)
+			}
+		}
+	case []interface{}:
+		if node.Kind != yamlv3.SequenceNode || len(node.Content) != len(val) {
+			return
+		}
+		for i, item := range node.Content {
+			doc.recordLoaded(item, val[i])
+		}
+	}
+}
+
+// holds checks whether node already has value, going by what was loaded or last written for it
+func (doc *yamlDocument) holds(node *yamlv3.Node, value interface{}) bool {
+	current, ok := doc.loaded[node]
+	if !ok {
+		if err := node.Decode(&current); err != nil {
+			return false
+		}
+	}
+
+	return sameYAMLValue(current, value)
+}
+
+// Encode updates the document to match nodegroups and writes it out
+func (doc *yamlDocument) Encode(nodegroups map[string]Nodegroup) ([]byte, error) {
+	if err := doc.sync(nodegroups); err != nil {
+		return []byte{}, err
+	}
+
+	emitter := &yamlEmitter{doc: doc}
+	if doc.preamble != "" {
+		emitter.buffer.WriteString(doc.preamble + "\n")
+	}
+	if doc.documentStart {
+		emitter.buffer.WriteString("---\n")
+	}
+	emitter.comment(doc.root.HeadComment, 0)
+	if err := emitter.mapping(doc.root.Content[0], 0, ""); err != nil {
+		return []byte{}, err
+	}
+	emitter.comment(doc.root.FootComment, 0)
+
+	return emitter.buffer.Bytes(), nil
+}
+
+// renameNodegroup renames a nodegroup's key in place, so it keeps its position and comments
+func (doc *yamlDocument) renameNodegroup(oldName string, newName string) {
+	mapping := doc.root.Content[0]
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == oldName {
+			mapping.Content[i].Value = newName
+			return
+		}
+	}
+}
+
+func (doc *yamlDocument) sync(nodegroups map[string]Nodegroup) error {
+	mapping := doc.root.Content[0]
+
+	kept := make([]*yamlv3.Node, 0, len(mapping.Content))
+	existing := make(map[string]bool)
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		key, value := mapping.Content[i], mapping.Content[i+1]
+		nodegroup, ok := nodegroups[key.Value]
+		if !ok {
+			continue
+		}
+
+		existing[key.Value] = true
+		if err := doc.syncNode(value, nodegroupValues(nodegroup), NodegroupFields); err != nil {
+			return err
+		}
+		kept = append(kept, key, value)
+	}
+
+	names := make([]string, 0)
+	for name := range nodegroups {
+		if !existing[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		value := &yamlv3.Node{}
+		if err := value.Encode(nodegroups[name]); err != nil {
+			return err
+		}
+		kept = append(kept, yamlKeyNode(name), value)
+	}
+
+	mapping.Content = kept
+	return nil
+}
+
+// syncNode changes node in place to hold value, keeping whatever already matches. New keys of a
+// mapping are added in keyOrder, then alphabetically.
+func (doc *yamlDocument) syncNode(node *yamlv3.Node, value interface{}, keyOrder []string) error {
+	if doc.holds(node, value) {
+		return nil
+	}
+
+	mapValue, isMap := value.(map[string]interface{})
+	listValue, isList := value.([]interface{})
+
+	var err error
+	switch {
+	case isMap && node.Kind == yamlv3.MappingNode:
+		err = doc.syncMapping(node, mapValue, keyOrder)
+	case isList && node.Kind == yamlv3.SequenceNode:
+		err = doc.syncSequence(node, listValue)
+	default:
+		err = replaceYAMLNode(node, value)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Copied, as the ENC keeps changing its own values in place
+	doc.loaded[node] = copyValue(value)
+	return nil
+}
+
+func (doc *yamlDocument) syncMapping(node *yamlv3.Node, values map[string]interface{}, keyOrder []string) error {
+	kept := make([]*yamlv3.Node, 0, len(node.Content))
+	existing := make(map[string]bool)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		newValue, ok := values[key.Value]
+		if !ok {
+			// An empty value reads the same as a missing one, so leave it be
+			if isEmptyYAMLNode(value) {
+				kept = append(kept, key, value)
+			}
+			continue
+		}
+
+		existing[key.Value] = true
+		if err := doc.syncNode(value, newValue, []string{}); err != nil {
+			return err
+		}
+		kept = append(kept, key, value)
+	}
+
+	keys := make([]string, 0)
+	for _, key := range keyOrder {
+		if _, ok := values[key]; ok && !existing[key] {
+			keys = append(keys, key)
+			existing[key] = true
+		}
+	}
+
+	remaining := make([]string, 0)
+	for key := range values {
+		if !existing[key] {
+			remaining = append(remaining, key)
+		}
+	}
+	sort.Strings(remaining)
+
+	for _, key := range append(keys, remaining...) {
+		value := &yamlv3.Node{}
+		if err := value.Encode(values[key]); err != nil {
+			return err
+		}
+		kept = append(kept, yamlKeyNode(key), value)
+	}
+
+	node.Content = kept
+	return nil
+}
+
+// syncSequence reuses items that still hold the same value, wherever they moved, so removing an
+// item doesn't shift the comments of the ones after it
+func (doc *yamlDocument) syncSequence(node *yamlv3.Node, values []interface{}) error {
+	items := make([]*yamlv3.Node, 0, len(values))
+	next := 0
+	for i, value := range values {
+		if match := doc.findItem(node.Content[next:], value); match != -1 {
+			items = append(items, node.Content[next+match])
+			next += match + 1
+			continue
+		}
+
+		// Change the next unmatched item in place, unless a later value still wants it
+		if next < len(node.Content) && !doc.itemWanted(node.Content[next], values[i+1:]) {
+			if err := doc.syncNode(node.Content[next], value, []string{}); err != nil {
+				return err
+			}
+			items = append(items, node.Content[next])
+			next++
+			continue
+		}
+
+		item := &yamlv3.Node{}
+		if err := item.Encode(value); err != nil {
+			return err
+		}
+		items = append(items, item)
+	}
+
+	node.Content = items
+	return nil
+}
+
+func (doc *yamlDocument) findItem(items []*yamlv3.Node, value interface{}) int {
+	for i, item := range items {
+		if doc.holds(item, value) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func (doc *yamlDocument) itemWanted(item *yamlv3.Node, values []interface{}) bool {
+	for _, value := range values {
+		if doc.holds(item, value) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// replaceYAMLNode swaps the contents of node for value, keeping its comments and, for strings,
+// its quoting
+func replaceYAMLNode(node *yamlv3.Node, value interface{}) error {
+	replacement := yamlv3.Node{}
+	if err := replacement.Encode(value); err != nil {
+		return err
+	}
+
+	if node.Kind == yamlv3.ScalarNode && replacement.Kind == yamlv3.ScalarNode && replacement.Tag == "!!str" {
+		if quoted := node.Style & (yamlv3.DoubleQuotedStyle | yamlv3.SingleQuotedStyle); quoted != 0 {
+			replacement.Style = quoted
+		}
+	}
+
+	replacement.HeadComment = node.HeadComment
+	replacement.LineComment = node.LineComment
+	replacement.FootComment = node.FootComment
+	*node = replacement
+
+	return nil
+}
+
+func yamlKeyNode(key string) *yamlv3.Node {
+	node := &yamlv3.Node{}
+	node.SetString(key)
+	return node
+}
+
+// sameYAMLValue compares through JSON, which doesn't care whether a number is an int or a float
+func sameYAMLValue(a interface{}, b interface{}) bool {
+	encodedA, errA := json.Marshal(a)
+	encodedB, errB := json.Marshal(b)
+
+	return errA == nil && errB == nil && bytes.Equal(encodedA, encodedB)
+}
+
+func isEmptyYAMLNode(node *yamlv3.Node) bool {
+	switch node.Kind {
+	case yamlv3.ScalarNode:
+		return node.Tag == "!!null"
+	case yamlv3.MappingNode, yamlv3.SequenceNode:
+		return len(node.Content) == 0
+	}
+
+	return false
+}
+
+func usesAnchors(node *yamlv3.Node) bool {
+	if node.Anchor != "" || node.Kind == yamlv3.AliasNode {
+		return true
+	}
+
+	for _, child := range node.Content {
+		if usesAnchors(child) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func walkYAMLPairs(node *yamlv3.Node, visit func(key *yamlv3.Node, value *yamlv3.Node)) {
+	switch node.Kind {
+	case yamlv3.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			visit(node.Content[i], node.Content[i+1])
+			walkYAMLPairs(node.Content[i+1], visit)
+		}
+	case yamlv3.SequenceNode:
+		for _, item := range node.Content {
+			walkYAMLPairs(item, visit)
+		}
+	}
+}
+
+// markBlankLines records which keys and sequence items had a blank line above them (or above
+// their comments) in the original file
+func markBlankLines(node *yamlv3.Node, lines []string, blankBefore map[*yamlv3.Node]bool) {
+	check := func(child *yamlv3.Node) {
+		first := child.Line
+		if child.HeadComment != "" {
+			first -= strings.Count(child.HeadComment, "\n") + 1
+		}
+		if first >= 2 && first-2 < len(lines) && strings.TrimSpace(lines[first-2]) == "" {
+			blankBefore[child] = true
+		}
+	}
+
+	switch node.Kind {
+	case yamlv3.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			check(node.Content[i])
+			markBlankLines(node.Content[i+1], lines, blankBefore)
+		}
+	case yamlv3.SequenceNode:
+		for _, item := range node.Content {
+			check(item)
+			markBlankLines(item, lines, blankBefore)
+		}
+	}
+}
+
+// yamlEmitter writes block mappings and sequences itself, so the indentation matches the
+// original file, and leaves scalars and flow collections to yaml.v3
+type yamlEmitter struct {
+	doc    *yamlDocument
+	buffer bytes.Buffer
+}
+
+func (e *yamlEmitter) comment(text string, indent int) {
+	if text == "" {
+		return
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		if line == "" {
+			e.buffer.WriteString("\n")
+			continue
+		}
+		e.buffer.WriteString(strings.Repeat(" ", indent) + line + "\n")
+	}
+}
+
+func (e *yamlEmitter) lineComment(nodes ...*yamlv3.Node) string {
+	for _, node := range nodes {
+		if node.LineComment != "" {
+			return " " + node.LineComment
+		}
+	}
+
+	return ""
+}
+
+// mapping writes a block mapping at indent. firstPrefix replaces the indentation of the first key
+// when the mapping starts on a sequence item's line.
+func (e *yamlEmitter) mapping(node *yamlv3.Node, indent int, firstPrefix string) error {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+
+		prefix := strings.Repeat(" ", indent)
+		if i == 0 && firstPrefix != "" {
+			prefix = firstPrefix
+		} else {
+			if e.doc.blankBefore[key] {
+				e.buffer.WriteString("\n")
+			}
+			e.comment(key.HeadComment, indent)
+		}
+
+		keyText, err := e.inline(key, indent)
+		if err != nil {
+			return err
+		}
+		e.buffer.WriteString(prefix + keyText + ":")
+
+		switch {
+		case isBlockCollection(value, yamlv3.MappingNode):
+			e.buffer.WriteString(e.lineComment(key, value) + "\n")
+			e.comment(value.HeadComment, indent+e.doc.indent)
+			if err := e.mapping(value, indent+e.doc.indent, ""); err != nil {
+				return err
+			}
+		case isBlockCollection(value, yamlv3.SequenceNode):
+			e.buffer.WriteString(e.lineComment(key, value) + "\n")
+			sequenceIndent := indent + e.doc.indent
+			if e.doc.compactSequences {
+				sequenceIndent = indent
+			}
+			e.comment(value.HeadComment, sequenceIndent)
+			if err := e.sequence(value, sequenceIndent); err != nil {
+				return err
+			}
+		default:
+			valueText, err := e.inline(value, indent)
+			if err != nil {
+				return err
+			}
+			if valueText != "" {
+				valueText = " " + valueText
+			}
+			e.buffer.WriteString(valueText + e.lineComment(value, key) + "\n")
+		}
+
+		e.comment(key.FootComment, indent)
+		e.comment(value.FootComment, indent)
+	}
+
+	return nil
+}
+
+func (e *yamlEmitter) sequence(node *yamlv3.Node, indent int) error {
+	for _, item := range node.Content {
+		if e.doc.blankBefore[item] {
+			e.buffer.WriteString("\n")
+		}
+		e.comment(item.HeadComment, indent)
+
+		prefix := strings.Repeat(" ", indent) + "- "
+		switch {
+		case isBlockCollection(item, yamlv3.MappingNode):
+			if err := e.mapping(item, indent+2, prefix); err != nil {
+				return err
+			}
+		case isBlockCollection(item, yamlv3.SequenceNode):
+			e.buffer.WriteString(strings.TrimRight(prefix, " ") + e.lineComment(item) + "\n")
+			if err := e.sequence(item, indent+2); err != nil {
+				return err
+			}
+		default:
+			itemText, err := e.inline(item, indent)
+			if err != nil {
+				return err
+			}
+			e.buffer.WriteString(strings.TrimRight(prefix+itemText, " ") + e.lineComment(item) + "\n")
+		}
+
+		e.comment(item.FootComment, indent)
+	}
+
+	return nil
+}
+
+// inline renders a scalar or flow collection, indenting any continuation lines under indent
+func (e *yamlEmitter) inline(node *yamlv3.Node, indent int) (string, error) {
+	switch {
+	case node.Kind == yamlv3.ScalarNode && node.Tag == "!!null" && node.Value == "":
+		return "", nil
+	case node.Kind == yamlv3.MappingNode && len(node.Content) == 0:
+		return "{}", nil
+	case node.Kind == yamlv3.SequenceNode && len(node.Content) == 0:
+		return "[]", nil
+	}
+
+	stripped := *node
+	stripped.HeadComment, stripped.LineComment, stripped.FootComment = "", "", ""
+
+	var buffer bytes.Buffer
+	encoder := yamlv3.NewEncoder(&buffer)
+	encoder.SetIndent(e.doc.indent)
+	if err := encoder.Encode(&stripped); err != nil {
+		return "", err
+	}
+	encoder.Close()
+
+	text := strings.TrimSuffix(buffer.String(), "\n")
+	return strings.Replace(text, "\n", "\n"+strings.Repeat(" ", indent), -1), nil
+}
+
+func isBlockCollection(node *yamlv3.Node, kind yamlv3.Kind) bool {
+	return node.Kind == kind && node.Style&yamlv3.FlowStyle == 0 && len(node.Content) > 0
+}
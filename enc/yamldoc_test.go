@@ -0,0 +1,152 @@
+package enc
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var preservedYAML = `# Production nodegroups, managed with go-enc
+---
+globals:
+  # Everything inherits from here
+  classes:
+    ntp:
+      servers: [0.pool.ntp.org, 1.pool.ntp.org]
+  parameters:
+    datacenter: "dub1" # primary site
+    motd: |
+      Managed by Puppet.
+      Do not edit.
+    admin_uid: '1234567'
+
+website:
+  parent: globals@production
+  parameters:
+  classes:
+    nginx:
+      worker_processes: 8
+      upstreams:
+      - name: app
+        port: 8080
+  nodes:
+  - web-0001 # canary
+  - web-0002
+`
+
+func writePreservedYAML(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "go-enc-yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fileName := filepath.Join(dir, "production.yaml")
+	if err := ioutil.WriteFile(fileName, []byte(preservedYAML), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return fileName, func() { os.RemoveAll(dir) }
+}
+
+func TestYAMLWriteOutUnchanged(t *testing.T) {
+	assert := assert.New(t)
+	fileName, cleanup := writePreservedYAML(t)
+	defer cleanup()
+
+	NewConfig(fileName).WriteOutENC()
+
+	contents, err := ioutil.ReadFile(fileName)
+	assert.Nil(err)
+	assert.Equal(preservedYAML, string(contents))
+}
+
+func TestYAMLWriteOutChanges(t *testing.T) {
+	assert := assert.New(t)
+	fileName, cleanup := writePreservedYAML(t)
+	defer cleanup()
+
+	c := NewConfig(fileName)
+	production := c.ENCs["production"]
+	production.SetParameter("globals", "datacenter", "dub2")
+	production.AddClassParameter("website", "nginx", "worker_processes", 4)
+	production.AddNode("website", "web-0003")
+	production.AddNodegroup("database", "globals@production", map[string]interface{}{"mysql": map[string]interface{}{}}, []string{}, map[string]interface{}{})
+	c.WriteOutENC()
+
+	contents, err := ioutil.ReadFile(fileName)
+	assert.Nil(err)
+
+	want := strings.Replace(preservedYAML, `datacenter: "dub1" # primary site`, `datacenter: "dub2" # primary site`, 1)
+	want = strings.Replace(want, "worker_processes: 8", "worker_processes: 4", 1)
+	want = strings.Replace(want, "  - web-0002\n", "  - web-0002\n  - web-0003\n", 1)
+	want += "database:\n  parent: globals@production\n  classes:\n    mysql: {}\n"
+	assert.Equal(want, string(contents))
+
+	// The rewritten file still reads back the same
+	reread := NewConfig(fileName).ENCs["production"]
+	assert.Equal(production.Nodegroups, reread.Nodegroups)
+}
+
+func TestYAMLWriteOutRemovals(t *testing.T) {
+	assert := assert.New(t)
+	fileName, cleanup := writePreservedYAML(t)
+	defer cleanup()
+
+	c := NewConfig(fileName)
+	production := c.ENCs["production"]
+	production.RemoveNode("website", "web-0001")
+	globals := production.Nodegroups["globals"]
+	delete(globals.Parameters, "motd")
+	c.WriteOutENC()
+
+	contents, err := ioutil.ReadFile(fileName)
+	assert.Nil(err)
+
+	want := strings.Replace(preservedYAML, "    motd: |\n      Managed by Puppet.\n      Do not edit.\n", "", 1)
+	want = strings.Replace(want, "  - web-0001 # canary\n", "", 1)
+	assert.Equal(want, string(contents))
+}
+
+func TestYAMLDocumentFallback(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Nil(parseYAMLDocument([]byte("base: &base\n  parent: x\nother: *base\n")))
+	assert.Nil(parseYAMLDocument([]byte("- not a mapping\n")))
+	assert.NotNil(parseYAMLDocument([]byte("globals:\n    parameters:\n        a: 1\n")))
+	assert.Equal(4, parseYAMLDocument([]byte("globals:\n    parameters:\n        a: 1\n")).indent)
+	assert.False(parseYAMLDocument([]byte("globals:\n    nodes:\n        - a\n")).compactSequences)
+}
+
+func TestYAMLWriteOutKeepsYAML11Scalars(t *testing.T) {
+	assert := assert.New(t)
+
+	original := `globals:
+  parameters:
+    datacenter: dub1
+    backups: yes
+    umask: 0755
+    since: 2020-01-01
+website:
+  parameters:
+    gzip: yes
+    mode: 0644
+    launched: 2019-06-30
+`
+	dir, err := ioutil.TempDir("", "go-enc-yaml")
+	assert.Nil(err)
+	defer os.RemoveAll(dir)
+	fileName := filepath.Join(dir, "production.yaml")
+	assert.Nil(ioutil.WriteFile(fileName, []byte(original), 0644))
+
+	c := NewConfig(fileName)
+	c.ENCs["production"].SetParameter("globals", "datacenter", "dub2")
+	c.WriteOutENC()
+
+	contents, err := ioutil.ReadFile(fileName)
+	assert.Nil(err)
+	assert.Equal(strings.Replace(original, "datacenter: dub1", "datacenter: dub2", 1), string(contents))
+}